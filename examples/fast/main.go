@@ -12,6 +12,7 @@ import (
 func main() {
 	a, val := gof.App, new(handler)
 	g2util.InjectPopulate(val, a.Default())
+	a.G2cmd.SetJ2Service(val)
 	startFunc := func() {
 		a.Gin.SetJ2Service(val)
 		a.Gin.Run()
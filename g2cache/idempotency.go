@@ -0,0 +1,77 @@
+package g2cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/atcharles/gof/v2/g2cache/store"
+	"github.com/atcharles/gof/v2/j2rpc"
+)
+
+// idempotencyStore ...j2rpc.IdempotencyStore, 过期时间保存在值的前8个字节
+type idempotencyStore struct {
+	inc store.ItfCache
+
+	mu    sync.Mutex
+	seq   uint64
+	locks map[string]idempotencyLock
+}
+
+// idempotencyLock ...执行锁的持有者与过期时间
+type idempotencyLock struct {
+	token   string
+	expires time.Time
+}
+
+// NewIdempotencyStore ...使用 store 保存 j2rpc 幂等请求的响应; 执行锁在进程内, 只适用于单实例部署
+func NewIdempotencyStore(inc store.ItfCache) j2rpc.IdempotencyStore {
+	return &idempotencyStore{inc: inc, locks: make(map[string]idempotencyLock)}
+}
+
+func (s *idempotencyStore) Lock(_ context.Context, key string, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if l, ok := s.locks[key]; ok && now.Before(l.expires) {
+		return "", nil
+	}
+	s.seq++
+	l := idempotencyLock{token: strconv.FormatUint(s.seq, 10), expires: now.Add(ttl)}
+	s.locks[key] = l
+	return l.token, nil
+}
+
+func (s *idempotencyStore) Unlock(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	if l, ok := s.locks[key]; ok && l.token == token {
+		delete(s.locks, key)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *idempotencyStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := s.inc.Get(key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || time.Now().UnixNano() >= int64(binary.BigEndian.Uint64(data)) {
+		_ = s.inc.Delete(key)
+		return nil, nil
+	}
+	return data[8:], nil
+}
+
+func (s *idempotencyStore) Set(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	buf := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(buf, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(buf[8:], data)
+	return s.inc.Set(key, buf)
+}
@@ -0,0 +1,33 @@
+package g2cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestIdempotencyLockToken ...过期的锁被其他请求获取后, 原持有者的 Unlock 不释放新的锁
+func TestIdempotencyLockToken(t *testing.T) {
+	ctx := context.Background()
+	s := NewIdempotencyStore(nil)
+	first, err := s.Lock(ctx, "k", time.Millisecond)
+	if err != nil || len(first) == 0 {
+		t.Fatalf("Lock = %q, %v", first, err)
+	}
+	if token, _ := s.Lock(ctx, "k", time.Minute); len(token) > 0 {
+		t.Fatal("locked twice")
+	}
+	time.Sleep(time.Millisecond * 2)
+	second, _ := s.Lock(ctx, "k", time.Minute)
+	if len(second) == 0 || second == first {
+		t.Fatalf("Lock after expiry = %q, first %q", second, first)
+	}
+	_ = s.Unlock(ctx, "k", first)
+	if token, _ := s.Lock(ctx, "k", time.Minute); len(token) > 0 {
+		t.Fatal("stale token released the lock")
+	}
+	_ = s.Unlock(ctx, "k", second)
+	if token, _ := s.Lock(ctx, "k", time.Minute); len(token) == 0 {
+		t.Fatal("owner token did not release the lock")
+	}
+}
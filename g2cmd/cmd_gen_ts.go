@@ -0,0 +1,43 @@
+package g2cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+type genTsCmd struct {
+	cmd *G2cmd
+	out string
+}
+
+func (g *genTsCmd) Cmd() *cobra.Command {
+	cmd1 := &cobra.Command{Use: "gen-ts", Run: g.Run}
+	g.SetFlags(cmd1)
+	return cmd1
+}
+
+func (g *genTsCmd) Run(*cobra.Command, []string) {
+	if g.cmd.j2Service == nil {
+		log.Fatalln("j2rpc 服务未设置, 请先调用 SetJ2Service")
+	}
+	//与 http 服务使用相同的选项与配置, 包括 ItfGinRouter.J2rpc 中的设置
+	jsv := g.cmd.Gin.NewJ2rpc(g.cmd.j2Service)
+
+	_ = os.MkdirAll(filepath.Dir(g.out), 0755)
+	f, err := os.Create(g.out)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer func() { _ = f.Close() }()
+	if err = jsv.TypeScript(f); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("TypeScript SDK 已生成: %s\n", g.out)
+}
+
+func (g *genTsCmd) SetFlags(c *cobra.Command) {
+	c.Flags().StringVarP(&g.out, "out", "o", "j2rpc.ts", "output file")
+}
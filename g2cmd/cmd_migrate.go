@@ -1,7 +1,11 @@
 package g2cmd
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
@@ -10,17 +14,23 @@ type migrateCmd struct {
 	cmd     *G2cmd
 	runFunc func()
 	drop    bool
+	dryRun  bool
 }
 
 func (m *migrateCmd) Cmd() *cobra.Command {
-	cmd1 := &cobra.Command{Use: "migrate", Run: m.Run}
+	cmd1 := &cobra.Command{
+		Use:   "migrate [up | down N | status]",
+		Short: "同步数据表并执行版本迁移; up 只执行版本迁移(新的数据库与不带参数时相同), down 回滚最后 N(默认1) 个版本, status 查看版本迁移的状态",
+		Args:  cobra.MaximumNArgs(2),
+		Run:   m.Run,
+	}
 	m.SetFlags(cmd1)
 	return cmd1
 }
 
-func (m *migrateCmd) Run(_ *cobra.Command, _ []string) {
+func (m *migrateCmd) Run(_ *cobra.Command, args []string) {
 	var err error
-	if m.drop {
+	if m.drop && !m.dryRun {
 		err = m.cmd.Mysql.DropDatabase()
 		if err != nil {
 			log.Fatalln(err)
@@ -30,11 +40,59 @@ func (m *migrateCmd) Run(_ *cobra.Command, _ []string) {
 			log.Println(err)
 		}
 	}
-	if m.runFunc != nil {
-		m.runFunc()
+	if len(args) == 0 {
+		if !m.dryRun {
+			if m.runFunc != nil {
+				m.runFunc()
+			}
+			return
+		}
+		args = []string{"up"}
+	}
+	db := m.cmd.Mysql
+	switch args[0] {
+	case "up":
+		err = db.MigrateUp(m.dryRun, os.Stdout)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				log.Fatalf("无效的回滚数量: %s\n", args[1])
+			}
+		}
+		err = db.MigrateDown(n, m.dryRun, os.Stdout)
+	case "status":
+		err = m.status()
+	default:
+		log.Fatalf("未知的命令: %s\n", args[0])
+	}
+	if err != nil {
+		log.Fatalln(err)
 	}
 }
 
 func (m *migrateCmd) SetFlags(c *cobra.Command) {
 	c.Flags().BoolVarP(&m.drop, "drop", "d", false, "drop database")
+	c.Flags().BoolVar(&m.dryRun, "dry-run", false, "print the SQL that would run")
+}
+
+// status ...
+func (m *migrateCmd) status() (err error) {
+	list, err := m.cmd.Mysql.MigrationStatus()
+	if err != nil {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, st := range list {
+		status := "pending"
+		if st.Applied != nil {
+			status = "applied " + st.Applied.String()
+		}
+		if st.Missing {
+			status += " (missing)"
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", st.Version, st.Name, status)
+	}
+	return w.Flush()
 }
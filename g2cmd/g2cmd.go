@@ -16,6 +16,7 @@ func (g *G2cmd) Execute() {
 	g.RegisterCmd(&stopCmd{cmd: g})
 	g.RegisterCmd(&restartCmd{cmd: g})
 	g.RegisterCmd(&migrateCmd{cmd: g, runFunc: g.migrateWorkerFunc})
+	g.RegisterCmd(&genTsCmd{cmd: g})
 
 	root := root1.Cmd()
 	for _, process := range g.cmdMap {
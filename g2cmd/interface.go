@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/atcharles/gof/v2/g2db"
+	"github.com/atcharles/gof/v2/g2gin"
 
 	"github.com/atcharles/gof/v2/g2util"
 )
@@ -18,11 +19,14 @@ type G2cmd struct {
 	Config *g2util.Config     `inject:""`
 	AbFile *g2util.AbFile     `inject:""`
 	Mysql  *g2db.Mysql        `inject:""`
+	Gin    *g2gin.G2gin       `inject:""`
 
 	cmdMap map[string]Process
 
 	startWorkerFunc   func()
 	migrateWorkerFunc func()
+
+	j2Service interface{}
 }
 
 // CmdMap ...
@@ -37,6 +41,9 @@ func (g *G2cmd) RegisterCmd(cmd Process) {
 	g.cmdMap[name] = cmd
 }
 
+// SetJ2Service ...设置 j2rpc 服务, 用于生成客户端代码
+func (g *G2cmd) SetJ2Service(j2Service interface{}) { g.j2Service = j2Service }
+
 // SetMigrateWorker ...
 func (g *G2cmd) SetMigrateWorker(fn func()) { g.migrateWorkerFunc = fn }
 
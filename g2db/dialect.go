@@ -0,0 +1,216 @@
+package g2db
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"xorm.io/xorm"
+)
+
+type (
+	//Dialect ...数据库方言, 由配置 mysql.dialect 选择(mysql, postgres, sqlite), 默认为 mysql
+	/**
+	驱动需要在 main 中导入, mysql 的驱动已经导入:
+	import _ "github.com/lib/pq"            //postgres
+	import _ "github.com/mattn/go-sqlite3"  //sqlite, 或 modernc.org/sqlite 并设置 mysql.driver: sqlite
+
+	mysql:
+	  dialect: 'postgres'
+	  dsn: 'postgres://root:123@{host}:5432/{db}?sslmode=disable'
+	*/
+	Dialect interface {
+		//DriverName ...xorm 的驱动名称, 配置 mysql.driver 不为空时使用配置的驱动
+		DriverName() string
+		//DataSource ...替换 dsn 中的 {db}, withDB 为 false 时为创建,删除数据库使用的连接
+		DataSource(dsn, db string, withDB bool) string
+		//CreateDatabase ...数据库不存在时创建
+		CreateDatabase(eg *xorm.Engine, db string) error
+		//DropDatabase ...
+		DropDatabase(eg *xorm.Engine, db string) error
+		//Charset ...同步数据表之后设置数据表的字符集
+		Charset(sn *xorm.Session, table string) error
+		//HasIndex ...数据表中是否存在索引
+		HasIndex(sn *xorm.Session, table, index string) (bool, error)
+		//CreateIndex ...
+		CreateIndex(sn *xorm.Session, table, index string, unique bool, columns []string) error
+	}
+
+	dialectMysql    struct{}
+	dialectPostgres struct{}
+	dialectSqlite   struct{}
+)
+
+var (
+	dialectMu sync.RWMutex
+	dialects  = map[string]Dialect{
+		"mysql":    new(dialectMysql),
+		"postgres": new(dialectPostgres),
+		"sqlite":   new(dialectSqlite),
+	}
+)
+
+// RegisterDialect ...注册数据库方言, 已存在时替换
+func RegisterDialect(name string, d Dialect) {
+	dialectMu.Lock()
+	defer dialectMu.Unlock()
+	dialects[name] = d
+}
+
+// GetDialect ...
+func GetDialect(name string) (d Dialect, err error) {
+	dialectMu.RLock()
+	defer dialectMu.RUnlock()
+	d, ok := dialects[name]
+	if !ok {
+		err = fmt.Errorf("不支持的数据库: %s", name)
+	}
+	return
+}
+
+// quoteIndexColumns ...
+func quoteIndexColumns(sn *xorm.Session, columns []string) string {
+	list := make([]string, 0, len(columns))
+	for _, c := range columns {
+		list = append(list, sn.Engine().Quote(c))
+	}
+	return strings.Join(list, ",")
+}
+
+// uniqueString ...
+func uniqueString(unique bool) string {
+	if unique {
+		return "UNIQUE "
+	}
+	return ""
+}
+
+func (*dialectMysql) DriverName() string { return "mysql" }
+
+func (*dialectMysql) DataSource(dsn, db string, withDB bool) string {
+	if withDB {
+		return strings.Replace(dsn, "{db}", db, -1)
+	}
+	return strings.Replace(dsn, "{db}", "", -1)
+}
+
+func (*dialectMysql) CreateDatabase(eg *xorm.Engine, db string) (err error) {
+	_, err = eg.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s;", eg.Quote(db)))
+	return
+}
+
+func (*dialectMysql) DropDatabase(eg *xorm.Engine, db string) (err error) {
+	_, err = eg.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s;", eg.Quote(db)))
+	return
+}
+
+func (*dialectMysql) Charset(sn *xorm.Session, table string) (err error) {
+	_, err = sn.Exec(fmt.Sprintf("ALTER TABLE %s CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;",
+		sn.Engine().Quote(table)))
+	return
+}
+
+// HasIndex ...索引名称作为参数传递
+func (*dialectMysql) HasIndex(sn *xorm.Session, table, index string) (bool, error) {
+	sq := fmt.Sprintf("SHOW INDEX FROM %s WHERE Key_name = ?", sn.Engine().Quote(table))
+	rs, err := sn.MustLogSQL(true).SQL(sq, index).Query()
+	if err != nil {
+		return false, fmt.Errorf("%w,\n%s", err, sq)
+	}
+	return len(rs) > 0, nil
+}
+
+func (*dialectMysql) CreateIndex(sn *xorm.Session, table, index string, unique bool, columns []string) (err error) {
+	_, err = sn.Exec(fmt.Sprintf("ALTER TABLE %s ADD %sINDEX %s (%s)", sn.Engine().Quote(table), uniqueString(unique),
+		sn.Engine().Quote(index), quoteIndexColumns(sn, columns)))
+	return
+}
+
+func (*dialectPostgres) DriverName() string { return "postgres" }
+
+// DataSource ...postgres 的连接必须指定数据库, 创建数据库时连接到 postgres
+func (*dialectPostgres) DataSource(dsn, db string, withDB bool) string {
+	if withDB {
+		return strings.Replace(dsn, "{db}", db, -1)
+	}
+	return strings.Replace(dsn, "{db}", "postgres", -1)
+}
+
+// CreateDatabase ...postgres 不支持 CREATE DATABASE IF NOT EXISTS, 数据库的编码为 UTF8
+func (*dialectPostgres) CreateDatabase(eg *xorm.Engine, db string) (err error) {
+	rs, err := eg.QueryString("SELECT 1 FROM pg_database WHERE datname = ?", db)
+	if err != nil || len(rs) > 0 {
+		return
+	}
+	_, err = eg.Exec(fmt.Sprintf(`CREATE DATABASE %s ENCODING 'UTF8'`, eg.Quote(db)))
+	return
+}
+
+func (*dialectPostgres) DropDatabase(eg *xorm.Engine, db string) (err error) {
+	_, err = eg.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", eg.Quote(db)))
+	return
+}
+
+// Charset ...字符集在创建数据库时指定
+func (*dialectPostgres) Charset(*xorm.Session, string) error { return nil }
+
+func (*dialectPostgres) HasIndex(sn *xorm.Session, table, index string) (bool, error) {
+	rs, err := sn.QueryString("SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND "+
+		"tablename = ? AND indexname = ?", table, index)
+	return len(rs) > 0, err
+}
+
+func (*dialectPostgres) CreateIndex(sn *xorm.Session, table, index string, unique bool, columns []string) (err error) {
+	_, err = sn.Exec(fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)", uniqueString(unique),
+		sn.Engine().Quote(index), sn.Engine().Quote(table), quoteIndexColumns(sn, columns)))
+	return
+}
+
+func (*dialectSqlite) DriverName() string { return "sqlite3" }
+
+// DataSource ...sqlite 的数据库为文件, dsn 如 file:{db}.db?cache=shared, 内存数据库 file::memory:?cache=shared
+func (*dialectSqlite) DataSource(dsn, db string, _ bool) string {
+	return strings.Replace(dsn, "{db}", db, -1)
+}
+
+// CreateDatabase ...连接时创建数据库文件
+func (*dialectSqlite) CreateDatabase(*xorm.Engine, string) error { return nil }
+
+// DropDatabase ...删除所有数据表
+func (*dialectSqlite) DropDatabase(eg *xorm.Engine, _ string) (err error) {
+	tables, err := eg.DBMetas()
+	if err != nil {
+		return
+	}
+	for _, t := range tables {
+		if _, err = eg.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", eg.Quote(t.Name))); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Charset ...sqlite 的字符集为 UTF-8
+func (*dialectSqlite) Charset(*xorm.Session, string) error { return nil }
+
+// HasIndex ...索引名称使用 xorm 的格式, xorm 同步数据表时才能删除
+func (*dialectSqlite) HasIndex(sn *xorm.Session, table, index string) (bool, error) {
+	rs, err := sn.QueryString("SELECT 1 FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name IN (?, ?)",
+		table, sqliteIndexName(table, index, true), sqliteIndexName(table, index, false))
+	return len(rs) > 0, err
+}
+
+func (*dialectSqlite) CreateIndex(sn *xorm.Session, table, index string, unique bool, columns []string) (err error) {
+	_, err = sn.Exec(fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)", uniqueString(unique),
+		sn.Engine().Quote(sqliteIndexName(table, index, unique)), sn.Engine().Quote(table),
+		quoteIndexColumns(sn, columns)))
+	return
+}
+
+// sqliteIndexName ...xorm 删除 sqlite 的索引时, 名称总是添加 UQE_table_ 或 IDX_table_ 前缀
+func sqliteIndexName(table, index string, unique bool) string {
+	if unique {
+		return fmt.Sprintf("UQE_%s_%s", table, index)
+	}
+	return fmt.Sprintf("IDX_%s_%s", table, index)
+}
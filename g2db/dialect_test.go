@@ -0,0 +1,176 @@
+package g2db
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atcharles/gof/v2/g2util"
+)
+
+type testTimeRow struct {
+	MyBase  `xorm:"extends"`
+	Name    string           `xorm:"varchar(32)"`
+	Expired *g2util.JSONTime `xorm:"datetime"`
+}
+
+func (*testTimeRow) TableName() string { return "test_time_row" }
+
+func TestGetDialect(t *testing.T) {
+	for _, name := range []string{"mysql", "postgres", "sqlite"} {
+		if _, err := GetDialect(name); err != nil {
+			t.Fatalf("GetDialect(%q): %v", name, err)
+		}
+	}
+	if _, err := GetDialect("oracle"); err == nil {
+		t.Fatal("GetDialect(oracle) should fail")
+	}
+}
+
+func TestDialectDataSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		dsn    string
+		withDB bool
+		want   string
+	}{
+		{"mysql", "root:1@tcp(h:3306)/{db}?loc=Local", true, "root:1@tcp(h:3306)/app?loc=Local"},
+		{"mysql", "root:1@tcp(h:3306)/{db}?loc=Local", false, "root:1@tcp(h:3306)/?loc=Local"},
+		{"postgres", "postgres://u@h:5432/{db}", true, "postgres://u@h:5432/app"},
+		{"postgres", "postgres://u@h:5432/{db}", false, "postgres://u@h:5432/postgres"},
+		{"sqlite", "file:{db}.db?cache=shared", false, "file:app.db?cache=shared"},
+	}
+	for _, tt := range tests {
+		d, _ := GetDialect(tt.name)
+		if got := d.DataSource(tt.dsn, "app", tt.withDB); got != tt.want {
+			t.Errorf("%s DataSource(withDB=%v) = %q, want %q", tt.name, tt.withDB, got, tt.want)
+		}
+	}
+}
+
+func TestSqliteIndexName(t *testing.T) {
+	if got := sqliteIndexName("user", "CUK_name", true); got != "UQE_user_CUK_name" {
+		t.Errorf("unique index name = %q", got)
+	}
+	if got := sqliteIndexName("user", "CIK_name", false); got != "IDX_user_CIK_name" {
+		t.Errorf("index name = %q", got)
+	}
+}
+
+// TestJSONTimeMysqlRoundTrip ...MySQL 中已有的 DATETIME 数据, 有无 parseTime 都读取为相同的本地时间
+func TestJSONTimeMysqlRoundTrip(t *testing.T) {
+	db, eg := newTestEngine(t, "root:1@tcp(jsontime)/app")
+	want := time.Date(2024, 5, 1, 12, 30, 0, 0, time.Local)
+	values := map[string]driver.Value{
+		"bytes":               []byte("2024-05-01 12:30:00"),
+		"parseTime":           time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		"parseTime&loc=Local": want,
+	}
+	for name, value := range values {
+		db.query = func(string, []driver.Value) ([]string, [][]driver.Value) {
+			return []string{"id", "created", "updated", "version", "name", "expired"},
+				[][]driver.Value{{int64(1), value, value, int64(1), "a", value}}
+		}
+		row := new(testTimeRow)
+		if _, err := eg.ID(1).Get(row); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !row.Created.Time().Equal(want) || row.Expired.Time().Hour() != 12 {
+			t.Errorf("%s: created = %s, want %s", name, row.Created.Time(), want)
+		}
+	}
+
+	row := &testTimeRow{Name: "b", Expired: g2util.NewJSONTimeOfTime(want)}
+	if _, err := eg.Insert(row); err != nil {
+		t.Fatal(err)
+	}
+	if !hasArg(db.lastArgs(), "2024-05-01 12:30:00") {
+		t.Errorf("insert args = %v, want expired as local DATETIME", db.lastArgs())
+	}
+
+	if _, err := eg.Exec("UPDATE test_time_row SET expired = ?", g2util.NewJSONTimeOfTime(want)); err != nil {
+		t.Fatal(err)
+	}
+	if !hasArg(db.lastArgs(), want.Format(time.RFC3339)) {
+		t.Errorf("raw args = %v, want ToDB format", db.lastArgs())
+	}
+}
+
+func TestJSONTimeScan(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 30, 0, 0, time.Local)
+	for _, v := range []interface{}{want, []byte("2024-05-01 12:30:00"), "2024-05-01 12:30:00"} {
+		p := new(g2util.JSONTime)
+		if err := p.Scan(v); err != nil {
+			t.Fatalf("Scan(%T): %v", v, err)
+		}
+		if !p.Time().Equal(want) {
+			t.Errorf("Scan(%T) = %s, want %s", v, p.Time(), want)
+		}
+	}
+	if err := new(g2util.JSONTime).Scan(1); err == nil {
+		t.Error("Scan(int) should fail")
+	}
+}
+
+// hasArg ...参数中存在以 prefix 开头的字符串
+func hasArg(args []driver.Value, prefix string) bool {
+	for _, a := range args {
+		var s string
+		switch v := a.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		}
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestDialectIndexStatements ...数据表, 索引与字段名称使用引号, 查询索引时名称作为参数传递
+func TestDialectIndexStatements(t *testing.T) {
+	tests := []struct {
+		dialect string
+		dsn     string
+		has     string
+		hasArgs []driver.Value
+		create  string
+	}{
+		{"mysql", "root:1@tcp(index)/app", "SHOW INDEX FROM `order` WHERE Key_name = ?",
+			[]driver.Value{"CUK_order_name_user_id"},
+			"ALTER TABLE `order` ADD UNIQUE INDEX `CUK_order_name_user_id` (`name`,`user_id`)"},
+		{"postgres", "postgres://root@index/app",
+			"SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1 AND indexname = $2",
+			[]driver.Value{"order", "CUK_order_name_user_id"},
+			`CREATE UNIQUE INDEX IF NOT EXISTS "CUK_order_name_user_id" ON "order" ("name","user_id")`},
+		{"sqlite", "file:index.db",
+			"SELECT 1 FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name IN (?, ?)",
+			[]driver.Value{"order", "UQE_order_CUK_order_name_user_id", "IDX_order_CUK_order_name_user_id"},
+			"CREATE UNIQUE INDEX IF NOT EXISTS `UQE_order_CUK_order_name_user_id` ON `order` (`name`,`user_id`)"},
+	}
+	for _, tt := range tests {
+		db, eg := newDialectEngine(t, tt.dialect, tt.dsn)
+		d, _ := GetDialect(tt.dialect)
+		sn := eg.NewSession()
+		if _, err := d.HasIndex(sn, "order", "CUK_order_name_user_id"); err != nil {
+			t.Fatalf("%s HasIndex: %v", tt.dialect, err)
+		}
+		if list := db.statements(); len(list) != 1 || list[0] != tt.has {
+			t.Errorf("%s HasIndex sql = %q, want %q", tt.dialect, list, tt.has)
+		}
+		if args := db.lastArgs(); !reflect.DeepEqual(args, tt.hasArgs) {
+			t.Errorf("%s HasIndex args = %v, want %v", tt.dialect, args, tt.hasArgs)
+		}
+		if err := d.CreateIndex(sn, "order", "CUK_order_name_user_id", true, []string{"name", "user_id"}); err != nil {
+			t.Fatalf("%s CreateIndex: %v", tt.dialect, err)
+		}
+		if list := db.statements(); list[len(list)-1] != tt.create {
+			t.Errorf("%s CreateIndex sql = %q, want %q", tt.dialect, list[len(list)-1], tt.create)
+		}
+		_ = sn.Close()
+	}
+}
@@ -1,5 +1,5 @@
 /**
-db for mysql use xorm;
+db for mysql(postgres, sqlite) use xorm;
 redis;
 */
 
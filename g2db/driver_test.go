@@ -0,0 +1,200 @@
+package g2db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"xorm.io/xorm"
+	xdialects "xorm.io/xorm/dialects"
+	"xorm.io/xorm/names"
+)
+
+// testDriverName ...测试使用的 database/sql 驱动, xorm 按 mysql 的方言生成 SQL
+const testDriverName = "g2db_test"
+
+// testDialectDrivers ...按 postgres, sqlite 的方言生成 SQL 的测试驱动
+var testDialectDrivers = map[string]string{
+	"mysql":    testDriverName,
+	"postgres": testDriverName + "_postgres",
+	"sqlite":   testDriverName + "_sqlite",
+}
+
+func init() {
+	sql.Register(testDriverName, new(testDriver))
+	xdialects.RegisterDriver(testDriverName, xdialects.QueryDriver("mysql"))
+	for name, xname := range map[string]string{"postgres": "postgres", "sqlite": "sqlite3"} {
+		sql.Register(testDialectDrivers[name], new(testDriver))
+		xdialects.RegisterDriver(testDialectDrivers[name], xdialects.QueryDriver(xname))
+	}
+}
+
+var testDBs sync.Map
+
+type (
+	//testDB ...按 dsn 区分的假数据库, 记录执行的语句, 查询的结果由 query 返回
+	testDB struct {
+		mu    sync.Mutex
+		execs []string
+		args  [][]driver.Value
+		query func(q string, args []driver.Value) (cols []string, rows [][]driver.Value)
+		down  bool
+	}
+
+	testDriver struct{}
+	testConn   struct{ db *testDB }
+	testStmt   struct {
+		db *testDB
+		q  string
+	}
+	testRows struct {
+		cols []string
+		rows [][]driver.Value
+		i    int
+	}
+	testResult struct{}
+)
+
+// newTestDB ...dsn 如 root:1@tcp(primary)/db
+func newTestDB(t *testing.T, dsn string) *testDB {
+	db := new(testDB)
+	testDBs.Store(dsn, db)
+	t.Cleanup(func() { testDBs.Delete(dsn) })
+	return db
+}
+
+// newTestEngine ...与 Mysql 的引擎相同使用 LintGonicMapper
+func newTestEngine(t *testing.T, dsn string) (*testDB, *xorm.Engine) {
+	return newDialectEngine(t, "mysql", dsn)
+}
+
+// newDialectEngine ...dialect 为 mysql, postgres, sqlite; postgres 的 dsn 如 postgres://root@host/db
+func newDialectEngine(t *testing.T, dialect, dsn string) (*testDB, *xorm.Engine) {
+	db := newTestDB(t, dsn)
+	eg, err := xorm.NewEngine(testDialectDrivers[dialect], dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eg.SetMapper(names.LintGonicMapper)
+	t.Cleanup(func() { _ = eg.Close() })
+	return db, eg
+}
+
+// setDown ...连接与查询失败
+func (db *testDB) setDown(down bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.down = down
+}
+
+// statements ...执行过的语句
+func (db *testDB) statements() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.execs...)
+}
+
+// lastArgs ...最后一条语句的参数
+func (db *testDB) lastArgs() []driver.Value {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.args) == 0 {
+		return nil
+	}
+	return db.args[len(db.args)-1]
+}
+
+func (db *testDB) record(q string, args []driver.Value) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.down {
+		return driver.ErrBadConn
+	}
+	db.execs = append(db.execs, q)
+	db.args = append(db.args, args)
+	return nil
+}
+
+func (*testDriver) Open(dsn string) (driver.Conn, error) {
+	v, ok := testDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("unknown test dsn: %s", dsn)
+	}
+	db := v.(*testDB)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.down {
+		return nil, fmt.Errorf("test dsn is down: %s", dsn)
+	}
+	return &testConn{db: db}, nil
+}
+
+func (c *testConn) Prepare(q string) (driver.Stmt, error) { return &testStmt{db: c.db, q: q}, nil }
+
+func (c *testConn) Close() error { return nil }
+
+func (c *testConn) Begin() (driver.Tx, error) { return c, c.db.record("BEGIN", nil) }
+
+func (c *testConn) Commit() error { return c.db.record("COMMIT", nil) }
+
+func (c *testConn) Rollback() error { return c.db.record("ROLLBACK", nil) }
+
+// Ping ...
+func (c *testConn) Ping() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if c.db.down {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (s *testStmt) Close() error { return nil }
+
+func (s *testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return testResult{}, s.db.record(s.q, args)
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.db.record(s.q, args); err != nil {
+		return nil, err
+	}
+	rows := new(testRows)
+	if s.db.query != nil {
+		rows.cols, rows.rows = s.db.query(s.q, args)
+	}
+	return rows, nil
+}
+
+func (r *testRows) Columns() []string { return r.cols }
+
+func (r *testRows) Close() error { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+func (testResult) LastInsertId() (int64, error) { return 1, nil }
+
+func (testResult) RowsAffected() (int64, error) { return 1, nil }
+
+// hasStatement ...
+func hasStatement(list []string, substr string) bool {
+	for _, s := range list {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,9 +5,12 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-redis/redis/v8"
+	"github.com/go-sql-driver/mysql"
 	"github.com/gookit/goutil/dump"
 	"github.com/pkg/errors"
 	"xorm.io/xorm"
+
+	"github.com/atcharles/gof/v2/j2rpc"
 )
 
 var (
@@ -27,6 +30,12 @@ func HasError(e error) (bool, error) {
 	return false, e
 }
 
+// J2rpcErrorMappings ...数据库错误的转换: ErrorMysqlNotFound 为 ErrNotFound, 驱动的错误不返回 SQL 的内容
+func J2rpcErrorMappings(opt *j2rpc.Option) {
+	opt.MapErrorType(new(ErrorMysqlNotFound), j2rpc.ErrorMapping{Code: j2rpc.ErrNotFound, Message: "数据不存在"})
+	opt.MapErrorType(new(*mysql.MySQLError), j2rpc.ErrorMapping{Code: j2rpc.ErrServer, Message: "数据库错误"})
+}
+
 // initializeTables 初始化数据表
 func initializeTables(table ItfInitData, sn *xorm.Session) (err error) {
 	tbName := tableName(table)
@@ -0,0 +1,56 @@
+package g2db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/atcharles/gof/v2/j2rpc"
+)
+
+// redisIdempotencyStore ...j2rpc.IdempotencyStore
+type redisIdempotencyStore struct{ r *redisObj }
+
+// unlockScript ...值为持有者的 token 时才删除, 锁过期后被其他实例获取时不删除
+var unlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// J2rpcIdempotencyStore ...使用 redis 保存 j2rpc 幂等请求的响应, 多个实例之间共享执行锁
+func (r *redisObj) J2rpcIdempotencyStore() j2rpc.IdempotencyStore {
+	return &redisIdempotencyStore{r: r}
+}
+
+// Lock ...值为随机的 token
+func (s *redisIdempotencyStore) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	ok, err := s.r.client().SetNX(ctx, s.r.formatWithAppName(key), token, ttl).Result()
+	if err != nil || !ok {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *redisIdempotencyStore) Unlock(ctx context.Context, key, token string) error {
+	return unlockScript.Run(ctx, s.r.client(), []string{s.r.formatWithAppName(key)}, token).Err()
+}
+
+func (s *redisIdempotencyStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.r.client().Get(ctx, s.r.formatWithAppName(key)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *redisIdempotencyStore) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return s.r.client().Set(ctx, s.r.formatWithAppName(key), data, ttl).Err()
+}
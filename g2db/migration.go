@@ -0,0 +1,397 @@
+package g2db
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"xorm.io/xorm"
+
+	"github.com/atcharles/gof/v2/g2util"
+)
+
+const (
+	statementBegin = "-- +StatementBegin"
+	statementEnd   = "-- +StatementEnd"
+	//migrationAlways ...SQL 文件中的这一行设置 Migration.Always
+	migrationAlways = "-- +Always"
+)
+
+type (
+	//Migration ...版本迁移, 按版本号从小到大执行, 每个版本在一个事务中执行并记录到 schema_migrations;
+	//Up/Down 为 Go 函数或 SQL(多条语句以行末的 ; 分隔), 同时设置时先执行 SQL;
+	//存储过程, 触发器与包含行末 ; 的多行字符串放在 -- +StatementBegin 与 -- +StatementEnd 两行之间, 作为一条语句执行;
+	//MySQL 的 DDL 会隐式提交事务, 同一个版本中不要混合 DDL 与需要回滚的数据修改;
+	//已有的数据库在 Sync2 之前执行, 依赖新字段的版本自己添加字段(ALTER TABLE ... ADD), Sync2 不会重复添加;
+	//新的数据库(没有数据表)由 Sync2 创建, 版本只记录不执行, 种子数据, 视图, 触发器等需要执行的版本设置 Always
+	/**
+	db.MigrationRegister(&g2db.Migration{
+		Version: 20240501120000,
+		Name:    "backfill_user_nickname",
+		UpSQL:   "UPDATE user SET nickname = name WHERE nickname = '';",
+		Down:    func(sn *xorm.Session) error { _, e := sn.Exec("UPDATE user SET nickname = ''"); return e },
+	})
+	*/
+	Migration struct {
+		Version int64
+		Name    string
+		Up      func(sn *xorm.Session) error
+		Down    func(sn *xorm.Session) error
+		UpSQL   string
+		DownSQL string
+		//新的数据库中也执行
+		Always bool
+	}
+
+	//SchemaMigration ...已执行的版本迁移
+	SchemaMigration struct {
+		Version int64            `json:"version" xorm:"pk 'version'"`
+		Name    string           `json:"name" xorm:"varchar(255) notnull"`
+		Applied *g2util.JSONTime `json:"applied,omitempty" xorm:"notnull default CURRENT_TIMESTAMP created"`
+	}
+
+	//MigrationStatus ...版本迁移的状态, Applied 为 nil 时未执行; Missing 为已执行但没有注册的版本
+	MigrationStatus struct {
+		Version int64            `json:"version"`
+		Name    string           `json:"name"`
+		Applied *g2util.JSONTime `json:"applied,omitempty"`
+		Missing bool             `json:"missing,omitempty"`
+	}
+)
+
+// TableName ...
+func (*SchemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationRegister ...注册版本迁移
+func (m *Mysql) MigrationRegister(list ...*Migration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.migrations = append(m.migrations, list...)
+}
+
+// MigrationRegisterFS ...注册目录中的 SQL 文件, 文件名为 {version}_{name}.up.sql 与 {version}_{name}.down.sql;
+// .up.sql 中有 -- +Always 一行时设置 Always
+/**
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+err := db.MigrationRegisterFS(migrations, "migrations")
+*/
+func (m *Mysql) MigrationRegisterFS(fsys fs.FS, dir string) (err error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return
+	}
+	mp := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(name, ".sql")
+		up := strings.HasSuffix(base, ".up")
+		if !up && !strings.HasSuffix(base, ".down") {
+			return errors.Errorf("版本迁移文件 %s 需要以 .up.sql 或 .down.sql 结尾", name)
+		}
+		base = strings.TrimSuffix(strings.TrimSuffix(base, ".up"), ".down")
+		ss := strings.SplitN(base, "_", 2)
+		version, e := strconv.ParseInt(ss[0], 10, 64)
+		if e != nil {
+			return errors.Errorf("版本迁移文件 %s 需要以版本号开头", name)
+		}
+		bts, e := fs.ReadFile(fsys, path.Join(dir, name))
+		if e != nil {
+			return e
+		}
+		g, ok := mp[version]
+		if !ok {
+			g = &Migration{Version: version}
+			if len(ss) > 1 {
+				g.Name = ss[1]
+			}
+			mp[version] = g
+		}
+		if up {
+			g.UpSQL = string(bts)
+			g.Always = hasLine(g.UpSQL, migrationAlways)
+		} else {
+			g.DownSQL = string(bts)
+		}
+	}
+	list := make([]*Migration, 0, len(mp))
+	for _, g := range mp {
+		list = append(list, g)
+	}
+	m.MigrationRegister(list...)
+	return
+}
+
+// MigrateUp ...执行所有未执行的版本迁移; dryRun 时只输出将要执行的 SQL 到 out;
+// 新的数据库与 Migrate 相同, 由 Sync2 创建数据表, 版本只记录不执行(Always 的除外)
+func (m *Mysql) MigrateUp(dryRun bool, out io.Writer) error {
+	return m.withEngine(func() (err error) {
+		baseline, err := m.freshSchema()
+		if err != nil {
+			return
+		}
+		if baseline && !dryRun {
+			return m.syncBaseline()
+		}
+		return m.migrateUp(baseline, dryRun, out)
+	})
+}
+
+// MigrateDown ...按版本从大到小回滚最后 n 个已执行的版本迁移
+func (m *Mysql) MigrateDown(n int, dryRun bool, out io.Writer) error {
+	return m.withEngine(func() (err error) {
+		list, applied, err := m.migrationState(!dryRun)
+		if err != nil {
+			return
+		}
+		for i := len(list) - 1; i >= 0 && n > 0; i-- {
+			g := list[i]
+			if _, ok := applied[g.Version]; !ok {
+				continue
+			}
+			n--
+			if g.Down == nil && len(strings.TrimSpace(g.DownSQL)) == 0 {
+				return errors.Errorf("版本迁移 %d %s 不能回滚", g.Version, g.Name)
+			}
+			if dryRun {
+				printMigration(out, g, "down", g.DownSQL, g.Down != nil)
+				continue
+			}
+			if err = m.TXCallback(func(sn *xorm.Session) (e error) {
+				if e = execMigration(sn, g.DownSQL, g.Down); e != nil {
+					return
+				}
+				_, e = sn.Delete(&SchemaMigration{Version: g.Version})
+				return
+			}); err != nil {
+				return errors.Errorf("回滚版本迁移 %d %s: %s", g.Version, g.Name, err.Error())
+			}
+			log.Printf("已回滚版本迁移: %d %s\n", g.Version, g.Name)
+		}
+		return
+	})
+}
+
+// MigrationStatus ...所有注册的版本迁移与已执行但没有注册的版本
+func (m *Mysql) MigrationStatus() (list []*MigrationStatus, err error) {
+	err = m.withEngine(func() (e error) {
+		migrations, applied, e := m.migrationState(false)
+		if e != nil {
+			return
+		}
+		for _, g := range migrations {
+			st := &MigrationStatus{Version: g.Version, Name: g.Name}
+			if row, ok := applied[g.Version]; ok {
+				st.Applied = row.Applied
+				delete(applied, g.Version)
+			}
+			list = append(list, st)
+		}
+		for _, row := range applied {
+			list = append(list, &MigrationStatus{Version: row.Version, Name: row.Name, Applied: row.Applied,
+				Missing: true})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+		return
+	})
+	return
+}
+
+// migrateUp ...baseline 为 true 时(新的数据库, 数据表由 Sync2 创建)只记录版本, 不执行 Always 以外的版本
+func (m *Mysql) migrateUp(baseline, dryRun bool, out io.Writer) (err error) {
+	list, applied, err := m.migrationState(!dryRun)
+	if err != nil {
+		return
+	}
+	if dryRun && baseline {
+		_, _ = fmt.Fprint(out, "-- 新的数据库: Sync2 创建数据表, 版本只记录不执行(Always 的除外)\n\n")
+	}
+	for _, g := range list {
+		if _, ok := applied[g.Version]; ok {
+			continue
+		}
+		run := !baseline || g.Always
+		if dryRun {
+			if run {
+				printMigration(out, g, "up", g.UpSQL, g.Up != nil)
+			} else {
+				printMigration(out, g, "baseline", "", false)
+			}
+			continue
+		}
+		if err = m.TXCallback(func(sn *xorm.Session) (e error) {
+			if run {
+				if e = execMigration(sn, g.UpSQL, g.Up); e != nil {
+					return
+				}
+			}
+			_, e = sn.Insert(&SchemaMigration{Version: g.Version, Name: g.Name})
+			return
+		}); err != nil {
+			return errors.Errorf("执行版本迁移 %d %s: %s", g.Version, g.Name, err.Error())
+		}
+		if run {
+			log.Printf("已执行版本迁移: %d %s\n", g.Version, g.Name)
+		}
+	}
+	return
+}
+
+// freshSchema ...新的数据库: 没有 schema_migrations 以外的数据表, 也没有已执行的版本
+func (m *Mysql) freshSchema() (fresh bool, err error) {
+	tables, err := m.eg.DBMetas()
+	if err != nil {
+		return
+	}
+	hasSchema := false
+	for _, t := range tables {
+		if t.Name != tableName(new(SchemaMigration)) {
+			return
+		}
+		hasSchema = true
+	}
+	if !hasSchema {
+		return true, nil
+	}
+	has, err := m.eg.Exist(new(SchemaMigration))
+	return !has, err
+}
+
+// migrationState ...按版本排序的版本迁移与已执行的版本; create 为 false 时不创建 schema_migrations
+func (m *Mysql) migrationState(create bool) (list []*Migration, applied map[int64]*SchemaMigration, err error) {
+	m.mu.RLock()
+	list = append(list, m.migrations...)
+	m.mu.RUnlock()
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			err = errors.Errorf("版本迁移的版本重复: %d", list[i].Version)
+			return
+		}
+	}
+	applied = make(map[int64]*SchemaMigration)
+	if create {
+		if err = m.eg.Sync2(new(SchemaMigration)); err != nil {
+			return
+		}
+	} else if has, e := m.eg.IsTableExist(new(SchemaMigration)); e != nil || !has {
+		err = e
+		return
+	}
+	rows := make([]*SchemaMigration, 0)
+	if err = m.eg.Find(&rows); err != nil {
+		return
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return
+}
+
+// withEngine ...没有连接数据库时(命令行)连接, 执行之后关闭
+func (m *Mysql) withEngine(fn func() error) (err error) {
+	if m.eg != nil {
+		return fn()
+	}
+	if err = m.dial(); err != nil {
+		return
+	}
+	defer func() { _ = m.eg.Close(); m.eg = nil }()
+	return fn()
+}
+
+// execMigration ...
+func execMigration(sn *xorm.Session, sq string, fn func(sn *xorm.Session) error) (err error) {
+	for _, s := range splitStatements(sq) {
+		if _, err = sn.Exec(s); err != nil {
+			return fmt.Errorf("%w,\n%s", err, s)
+		}
+	}
+	if fn != nil {
+		err = fn(sn)
+	}
+	return
+}
+
+// printMigration ...dryRun 的输出, Go 函数不能输出 SQL
+func printMigration(out io.Writer, g *Migration, direction, sq string, hasFunc bool) {
+	_, _ = fmt.Fprintf(out, "-- %d %s (%s)\n", g.Version, g.Name, direction)
+	for _, s := range splitStatements(sq) {
+		_, _ = fmt.Fprintf(out, "%s;\n", s)
+	}
+	if hasFunc {
+		_, _ = fmt.Fprintln(out, "-- Go 函数, 不能输出 SQL")
+	}
+	_, _ = fmt.Fprintln(out)
+}
+
+// hasLine ...
+func hasLine(s, line string) bool {
+	for _, l := range strings.Split(s, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
+
+// splitStatements ...以行末的 ; 分隔语句, 忽略只有注释的语句;
+// -- +StatementBegin 与 -- +StatementEnd 之间的行为一条语句(存储过程, 触发器, 多行字符串)
+func splitStatements(sq string) (list []string) {
+	var (
+		buf, code strings.Builder
+		block     bool
+	)
+	flush := func() {
+		if len(strings.TrimSpace(code.String())) > 0 {
+			list = append(list, strings.TrimSpace(buf.String()))
+		}
+		buf.Reset()
+		code.Reset()
+	}
+	for _, line := range strings.Split(sq, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch trimmed {
+		case statementBegin:
+			flush()
+			block = true
+			continue
+		case statementEnd:
+			s := strings.TrimSpace(buf.String())
+			buf.Reset()
+			buf.WriteString(strings.TrimSuffix(s, ";"))
+			flush()
+			block = false
+			continue
+		}
+		if block {
+			buf.WriteString(line)
+			buf.WriteString("\n")
+			code.WriteString(trimmed)
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+		if !strings.HasPrefix(trimmed, "--") {
+			code.WriteString(trimmed)
+		}
+		if strings.HasSuffix(trimmed, ";") && !strings.HasPrefix(trimmed, "--") {
+			s := strings.TrimSpace(buf.String())
+			buf.Reset()
+			buf.WriteString(strings.TrimSuffix(s, ";"))
+			flush()
+		}
+	}
+	flush()
+	return
+}
@@ -0,0 +1,270 @@
+package g2db
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"xorm.io/xorm"
+
+	"github.com/atcharles/gof/v2/g2util"
+)
+
+type migrateUser struct {
+	ID       int64  `xorm:"pk autoincr"`
+	Nickname string `xorm:"varchar(64)"`
+}
+
+// newMigrateTest ...tables 为 DBMetas 返回的数据表, applied 为 schema_migrations 中已执行的版本
+func newMigrateTest(t *testing.T, dsn string, tables []string, applied ...int64) (*testDB, *Mysql) {
+	db, eg := newTestEngine(t, dsn)
+	db.query = func(q string, _ []driver.Value) (cols []string, rows [][]driver.Value) {
+		switch {
+		case strings.Contains(q, "`TABLE_NAME`, `ENGINE`"):
+			cols = []string{"TABLE_NAME", "ENGINE", "AUTO_INCREMENT", "TABLE_COMMENT", "TABLE_COLLATION"}
+			for _, name := range tables {
+				rows = append(rows, []driver.Value{name, "InnoDB", nil, "", "utf8mb4_bin"})
+			}
+		case strings.Contains(q, "`TABLE_NAME` from"):
+			cols, rows = []string{"TABLE_NAME"}, [][]driver.Value{{"schema_migrations"}}
+		case strings.Contains(q, "FROM `schema_migrations`"):
+			cols = []string{"version", "name", "applied"}
+			for _, v := range applied {
+				rows = append(rows, []driver.Value{v, "applied", []byte("2024-05-01 12:00:00")})
+			}
+		}
+		return
+	}
+	cfg := new(g2util.Config)
+	cfg.Constructor()
+	m := &Mysql{Config: cfg, eg: eg, tables: []interface{}{new(migrateUser)}}
+	return db, m
+}
+
+// statementIndex ...第一个包含 substr 的语句的位置, 没有时为 -1
+func statementIndex(list []string, substr string) int {
+	for i, s := range list {
+		if strings.Contains(s, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		sq   string
+		want []string
+	}{
+		{"UPDATE a SET b = 1;\nUPDATE c\nSET d = 2;\n", []string{"UPDATE a SET b = 1", "UPDATE c\nSET d = 2"}},
+		{"-- comment;\nUPDATE a SET b = 1", []string{"-- comment;\nUPDATE a SET b = 1"}},
+		{"-- only comment\n\n", nil},
+		{
+			"UPDATE a SET b = 1;\n-- +StatementBegin\nCREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n" +
+				"  SET NEW.b = 1;\nEND;\n-- +StatementEnd\nUPDATE c SET d = 2;",
+			[]string{"UPDATE a SET b = 1", "CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n  SET NEW.b = 1;\nEND",
+				"UPDATE c SET d = 2"},
+		},
+		{
+			"-- +StatementBegin\nINSERT INTO note (body) VALUES ('line 1;\nline 2');\n-- +StatementEnd\n",
+			[]string{"INSERT INTO note (body) VALUES ('line 1;\nline 2')"},
+		},
+	}
+	for _, tt := range tests {
+		if got := splitStatements(tt.sq); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitStatements(%q) = %q, want %q", tt.sq, got, tt.want)
+		}
+	}
+}
+
+func TestMigrationRegisterFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/20240501120000_rename_nick.up.sql":   {Data: []byte("ALTER TABLE user RENAME COLUMN nick TO nickname;")},
+		"migrations/20240501120000_rename_nick.down.sql": {Data: []byte("ALTER TABLE user RENAME COLUMN nickname TO nick;")},
+		"migrations/20240502000000.up.sql":               {Data: []byte("-- +Always\nINSERT INTO role (name) VALUES ('admin');")},
+		"migrations/README.md":                           {Data: []byte("ignored")},
+	}
+	m := new(Mysql)
+	if err := m.MigrationRegisterFS(fsys, "migrations"); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int64]*Migration)
+	for _, g := range m.migrations {
+		got[g.Version] = g
+	}
+	if g := got[20240501120000]; g == nil || g.Name != "rename_nick" || !strings.Contains(g.UpSQL, "TO nickname") ||
+		!strings.Contains(g.DownSQL, "TO nick;") || g.Always {
+		t.Errorf("rename_nick = %+v", g)
+	}
+	if g := got[20240502000000]; g == nil || len(g.Name) > 0 || len(g.DownSQL) > 0 || !g.Always {
+		t.Errorf("20240502000000 = %+v", g)
+	}
+	if len(got) != 2 {
+		t.Errorf("registered %d migrations, want 2", len(got))
+	}
+
+	for _, name := range []string{"migrations/v1_bad.up.sql", "migrations/20240503_bad.sql"} {
+		bad := fstest.MapFS{name: {Data: []byte("SELECT 1;")}}
+		if err := new(Mysql).MigrationRegisterFS(bad, "migrations"); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+// TestSyncMigrateExisting ...已有的数据库在 Sync2 之前执行版本迁移
+func TestSyncMigrateExisting(t *testing.T) {
+	db, m := newMigrateTest(t, "root:1@tcp(migrate_existing)/app", []string{"migrate_user"}, 1)
+	m.MigrationRegister(
+		&Migration{Version: 1, Name: "applied", UpSQL: "UPDATE migrate_user SET nickname = 'applied';"},
+		&Migration{Version: 2, Name: "rename_nick", UpSQL: "ALTER TABLE migrate_user CHANGE nick nickname varchar(64);"},
+	)
+	if err := m.syncMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	list := db.statements()
+	rename, charset := statementIndex(list, "CHANGE nick nickname"), statementIndex(list, "CONVERT TO CHARACTER SET")
+	if rename < 0 || charset < 0 || rename > charset {
+		t.Errorf("rename at %d, sync at %d, want the migration before Sync2:\n%s", rename, charset,
+			strings.Join(list, "\n"))
+	}
+	if hasStatement(list, "nickname = 'applied'") {
+		t.Error("applied migration executed again")
+	}
+	if !hasStatement(list, "INSERT INTO `schema_migrations`") {
+		t.Error("migration not recorded")
+	}
+}
+
+// TestSyncMigrateBaseline ...新的数据库只记录版本, Always 的版本在 Sync2 之后执行
+func TestSyncMigrateBaseline(t *testing.T) {
+	db, m := newMigrateTest(t, "root:1@tcp(migrate_baseline)/app", nil)
+	m.MigrationRegister(
+		&Migration{Version: 1, Name: "rename_nick", UpSQL: "ALTER TABLE migrate_user CHANGE nick nickname varchar(64);"},
+		&Migration{Version: 2, Name: "seed", UpSQL: "INSERT INTO migrate_user (nickname) VALUES ('admin');",
+			Always: true},
+	)
+	if err := m.syncMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	list := db.statements()
+	if hasStatement(list, "CHANGE nick nickname") {
+		t.Error("baseline executed a migration without Always")
+	}
+	seed, charset := statementIndex(list, "VALUES ('admin')"), statementIndex(list, "CONVERT TO CHARACTER SET")
+	if seed < 0 || charset < 0 || seed < charset {
+		t.Errorf("seed at %d, sync at %d, want Always executed after Sync2:\n%s", seed, charset,
+			strings.Join(list, "\n"))
+	}
+	var recorded int
+	for _, s := range list {
+		if strings.Contains(s, "INSERT INTO `schema_migrations`") {
+			recorded++
+		}
+	}
+	if recorded != 2 {
+		t.Errorf("recorded %d versions, want 2", recorded)
+	}
+}
+
+// TestMigrateUpFresh ...migrate up 与 migrate 相同, 新的数据库由 Sync2 创建数据表, 版本只记录不执行
+func TestMigrateUpFresh(t *testing.T) {
+	migrations := []*Migration{
+		{Version: 1, Name: "rename_nick", UpSQL: "ALTER TABLE migrate_user CHANGE nick nickname varchar(64);"},
+		{Version: 2, Name: "seed", UpSQL: "INSERT INTO migrate_user (nickname) VALUES ('admin');", Always: true},
+	}
+	db, m := newMigrateTest(t, "root:1@tcp(migrate_up_fresh)/app", nil)
+	m.MigrationRegister(migrations...)
+	out := new(bytes.Buffer)
+	if err := m.MigrateUp(true, out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "-- 1 rename_nick (baseline)\n\n-- 2 seed (up)\nINSERT") {
+		t.Errorf("dry run = %q", out.String())
+	}
+	if list := db.statements(); hasStatement(list, "CHANGE nick") || hasStatement(list, "VALUES ('admin')") {
+		t.Errorf("dry run executed a migration:\n%s", strings.Join(list, "\n"))
+	}
+
+	if err := m.MigrateUp(false, nil); err != nil {
+		t.Fatal(err)
+	}
+	list := db.statements()
+	if hasStatement(list, "CHANGE nick nickname") {
+		t.Error("baseline executed a migration without Always")
+	}
+	seed, charset := statementIndex(list, "VALUES ('admin')"), statementIndex(list, "CONVERT TO CHARACTER SET")
+	if seed < 0 || charset < 0 || seed < charset {
+		t.Errorf("seed at %d, sync at %d, want Sync2 before Always:\n%s", seed, charset, strings.Join(list, "\n"))
+	}
+
+	//只有空的 schema_migrations 时仍为新的数据库, 有已执行的版本时按已有的数据库执行
+	for _, applied := range [][]int64{nil, {1}} {
+		db, m = newMigrateTest(t, fmt.Sprintf("root:1@tcp(migrate_up_schema_%d)/app", len(applied)),
+			[]string{"schema_migrations"}, applied...)
+		m.MigrationRegister(migrations...)
+		if err := m.MigrateUp(false, nil); err != nil {
+			t.Fatal(err)
+		}
+		list = db.statements()
+		if synced := hasStatement(list, "CONVERT TO CHARACTER SET"); synced != (len(applied) == 0) {
+			t.Errorf("applied %v: synced %t", applied, synced)
+		}
+		if len(applied) > 0 && (hasStatement(list, "CHANGE nick") || !hasStatement(list, "VALUES ('admin')")) {
+			t.Errorf("applied %v: want only version 2 executed:\n%s", applied, strings.Join(list, "\n"))
+		}
+	}
+}
+
+func TestMigrateDownAndStatus(t *testing.T) {
+	db, m := newMigrateTest(t, "root:1@tcp(migrate_down)/app", []string{"migrate_user", "schema_migrations"}, 1, 2, 9)
+	m.MigrationRegister(
+		&Migration{Version: 1, Name: "one", UpSQL: "UPDATE a SET v = 1;", DownSQL: "UPDATE a SET v = 0;"},
+		&Migration{Version: 2, Name: "two", UpSQL: "UPDATE a SET v = 2;",
+			Down: func(sn *xorm.Session) error { _, e := sn.Exec("UPDATE a SET v = 1"); return e }},
+		&Migration{Version: 3, Name: "three", UpSQL: "UPDATE a SET v = 3;\nUPDATE b SET v = 3;"},
+	)
+
+	list, err := m.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, st := range list {
+		got = append(got, fmt.Sprintf("%s:%t:%t", st.Name, st.Applied != nil, st.Missing))
+	}
+	want := []string{"one:true:false", "two:true:false", "three:false:false", "applied:true:true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("status = %v, want %v", got, want)
+	}
+
+	out := new(bytes.Buffer)
+	if err = m.MigrateUp(true, out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "-- 3 three (up)\nUPDATE a SET v = 3;\nUPDATE b SET v = 3;\n\n"; out.String() != want {
+		t.Errorf("dry run = %q, want %q", out.String(), want)
+	}
+	if hasStatement(db.statements(), "SET v = 3") {
+		t.Error("dry run executed the migration")
+	}
+
+	if err = m.MigrateDown(1, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	stmts := db.statements()
+	if !hasStatement(stmts, "UPDATE a SET v = 1") || hasStatement(stmts, "UPDATE a SET v = 0") {
+		t.Errorf("down 1 should roll back version 2 only:\n%s", strings.Join(stmts, "\n"))
+	}
+	if !hasStatement(stmts, "DELETE FROM `schema_migrations`") {
+		t.Error("rolled back version not deleted")
+	}
+
+	m.MigrationRegister(&Migration{Version: 3, Name: "duplicate"})
+	if _, err = m.MigrationStatus(); err == nil || !strings.Contains(err.Error(), "版本重复") {
+		t.Errorf("duplicate version: err = %v", err)
+	}
+}
@@ -55,7 +55,7 @@ type (
 func (c *CompoundIndex) SetSn(sn *xorm.Session) { c.sn = sn }
 
 // execCreate ...创建索引
-func (c *CompoundIndex) execCreate(table string) (err error) {
+func (c *CompoundIndex) execCreate(d Dialect, table string) (err error) {
 	if c.sn == nil {
 		panic("orm Session is nil!")
 	}
@@ -71,13 +71,6 @@ func (c *CompoundIndex) execCreate(table string) (err error) {
 		return
 	}
 
-	_unique := func() string {
-		if c.Unique {
-			return "UNIQUE "
-		}
-		return ""
-	}
-
 	_indexName := func() string {
 		prefix := "CUK"
 		if !c.Unique {
@@ -86,26 +79,13 @@ func (c *CompoundIndex) execCreate(table string) (err error) {
 		return fmt.Sprintf("%s_%s_%s", prefix, table, strings.Join(cs1, "_"))
 	}
 
-	mp1 := g2util.Map{
-		"table":      table,
-		"unique":     _unique(),
-		"index_name": _indexName(),
-		"indexes":    strings.Join(cs1, ","),
-	}
-
-	sq1 := `SHOW INDEX FROM {{.table}} WHERE Key_name = '{{.index_name}}'`
-	sq1 = g2util.TextTemplateMustParse(sq1, mp1)
-	rs1, err := c.sn.MustLogSQL(true).SQL(sq1).Query()
-	if err != nil {
-		return fmt.Errorf("查询复合索引失败: %w,\n%s", err, sq1)
-	}
-	if len(rs1) > 0 {
+	if has, e := d.HasIndex(c.sn, table, _indexName()); e != nil || has {
+		if e != nil {
+			return fmt.Errorf("查询复合索引失败: %w", e)
+		}
 		return
 	}
-	sq2 := `ALTER TABLE {{.table}} ADD {{.unique}}INDEX {{.index_name}} ({{.indexes}})`
-	sq2 = g2util.TextTemplateMustParse(sq2, mp1)
-	_, err = c.sn.Exec(sq2)
-	return
+	return d.CreateIndex(c.sn, table, _indexName(), c.Unique, cs1)
 }
 
 // makeQuery ...
@@ -154,27 +134,38 @@ type Mysql struct {
 	Cache     *cacheMem        `inject:""`
 	CacheBind *cacheBind       `inject:""`
 
-	mu     sync.RWMutex
-	eg     *xorm.Engine
-	out    io.Writer
-	tables []interface{}
+	mu         sync.RWMutex
+	eg         *xorm.Engine
+	out        io.Writer
+	tables     []interface{}
+	migrations []*Migration
+
+	replicas    []*replica
+	replicaNext uint64
+	replicaStop chan struct{}
 }
 
 // AfterShutdown ...
 func (m *Mysql) AfterShutdown() {
+	m.closeReplicas()
 	if m.eg != nil {
 		_ = m.eg.Close()
 	}
 }
 
-// CacheGet ...
+// CacheGet ...缓存不存在时从从库读取
 func (m *Mysql) CacheGet(bean interface{}, condition ...interface{}) (err error) {
-	return m.cacheGet(bean, []string{"Unscoped"}, condition...)
+	return m.cacheGet(m.ReadEngine(context.Background()), bean, []string{"Unscoped"}, condition...)
 }
 
-// CacheGetWrapSession ...
+// CacheGetContext ...请求中写入过数据时从主库读取
+func (m *Mysql) CacheGetContext(ctx context.Context, bean interface{}, condition ...interface{}) (err error) {
+	return m.cacheGet(m.ReadEngine(ctx), bean, []string{"Unscoped"}, condition...)
+}
+
+// CacheGetWrapSession ...缓存不存在时从从库读取
 func (m *Mysql) CacheGetWrapSession(bean interface{}, arg interface{}, condition ...interface{}) (err error) {
-	return m.cacheGet(bean, arg, condition...)
+	return m.cacheGet(m.ReadEngine(context.Background()), bean, arg, condition...)
 }
 
 // CacheMemKeys ...
@@ -220,7 +211,7 @@ func (m *Mysql) DelCache(bean interface{}, condition ...interface{}) (err error)
 	return m.Redis.PubDelCache(m.CacheMemKeys(bean, condition...))
 }
 
-// Delete ...
+// Delete ...不标记 read-your-writes, 见 MarkWritten
 func (m *Mysql) Delete(bean interface{}) (err error) {
 	return m.TXCallback(func(sn *xorm.Session) error { return m.Session(sn).Delete(bean) })
 }
@@ -230,16 +221,31 @@ func (m *Mysql) Dial() {
 	if e := m.dial(); e != nil {
 		log.Fatalf("数据库连接失败:%s\n", e.Error())
 	}
+	if e := m.dialReplicas(); e != nil {
+		log.Fatalf("从库连接失败:%s\n", e.Error())
+	}
 	//订阅Redis
 	m.Redis.Subscribe()
 	m.Grace.RegProcessor(m)
 	m.Grace.RegProcessor(m.Redis)
 }
 
-// DialWithMysql ......
+// Dialect ...配置 mysql.dialect 的数据库方言
+func (m *Mysql) Dialect() (d Dialect, err error) {
+	name := m.Config.Viper().GetString("mysql.dialect")
+	if len(name) == 0 {
+		name = "mysql"
+	}
+	return GetDialect(name)
+}
+
+// DialWithMysql ......不指定数据库的连接
 func (m *Mysql) DialWithMysql(fn func(x *xorm.Engine) error) (err error) {
-	dataSource := m.getDataSource(false)
-	dba, err := xorm.NewEngine("mysql", dataSource)
+	dataSource, err := m.getDataSource(false)
+	if err != nil {
+		return
+	}
+	dba, err := xorm.NewEngine(m.driverName(), dataSource)
 	if err != nil {
 		return
 	}
@@ -250,8 +256,11 @@ func (m *Mysql) DialWithMysql(fn func(x *xorm.Engine) error) (err error) {
 // DropDatabase ...
 func (m *Mysql) DropDatabase() (err error) {
 	log.Println("删除数据库")
-	sq := fmt.Sprintf(`DROP DATABASE IF EXISTS %s;`, m.DbName())
-	return m.ExecSqOnNewEngine(sq)
+	d, err := m.Dialect()
+	if err != nil {
+		return
+	}
+	return m.DialWithMysql(func(x *xorm.Engine) error { return d.DropDatabase(x, m.DbName()) })
 }
 
 // Engine ...
@@ -259,25 +268,21 @@ func (m *Mysql) Engine() *xorm.Engine { return m.eg }
 
 // ExecSqOnNewEngine ...
 func (m *Mysql) ExecSqOnNewEngine(sq string) (err error) {
-	dataSource := m.getDataSource(false)
-	dba, err := xorm.NewEngine("mysql", dataSource)
-	if err != nil {
+	return m.DialWithMysql(func(x *xorm.Engine) (e error) {
+		_, e = x.Exec(sq)
 		return
-	}
-	defer func() { _ = dba.Close() }()
-	_, err = dba.Exec(sq)
-	return
+	})
 }
 
 // GetOut ...
 func (m *Mysql) GetOut() io.Writer { return m.getOut() }
 
-// Insert ...
+// Insert ...不标记 read-your-writes, 见 MarkWritten
 func (m *Mysql) Insert(bean interface{}) error {
 	return m.TXCallback(func(sn *xorm.Session) error { return m.Session(sn).Insert(bean) })
 }
 
-// Migrate ...数据库初始化
+// Migrate ...数据库初始化: 已有的数据库先执行未执行的版本迁移, 再同步数据表
 func (m *Mysql) Migrate() {
 	d := g2util.TimeExcWrap(func() {
 		if e := m.migrate(); e != nil {
@@ -287,7 +292,7 @@ func (m *Mysql) Migrate() {
 	log.Printf("数据初始化完成,use:%s\n", d)
 }
 
-// Session ...
+// Session ...不标记 read-your-writes, 见 MarkWritten
 func (m *Mysql) Session(sn *xorm.Session) *Session { return newSession(m, sn) }
 
 // SetEngine ......
@@ -319,7 +324,7 @@ func (m *Mysql) TableRegister(tables ...interface{}) {
 // Tables ...
 func (m *Mysql) Tables() []interface{} { return m.tables }
 
-// TXCallback ...
+// TXCallback ...不标记 read-your-writes, 请求中使用 TXCallbackContext
 func (m *Mysql) TXCallback(fn func(sn *xorm.Session) (err error)) (err error) {
 	sn := m.eg.NewSession()
 	defer func() { _ = sn.Close() }() //不管是否存在err,总是close
@@ -339,7 +344,7 @@ func (m *Mysql) TXCallback(fn func(sn *xorm.Session) (err error)) (err error) {
 	return
 }
 
-// Update ...
+// Update ...不标记 read-your-writes, 见 MarkWritten
 func (m *Mysql) Update(bean interface{}, params ...interface{}) (newBean interface{}, err error) {
 	err = m.TXCallback(func(sn *xorm.Session) error {
 		v, e := m.Session(sn).Update(bean, params...)
@@ -352,7 +357,7 @@ func (m *Mysql) Update(bean interface{}, params ...interface{}) (newBean interfa
 	return
 }
 
-func (m *Mysql) cacheGet(bean interface{}, arg interface{}, condition ...interface{}) (err error) {
+func (m *Mysql) cacheGet(eg *xorm.Engine, bean interface{}, arg interface{}, condition ...interface{}) (err error) {
 	queryList := m.CacheBind.Values(bean, condition...)
 	if len(queryList) == 0 {
 		return errors.New("查询条件为空")
@@ -361,7 +366,7 @@ func (m *Mysql) cacheGet(bean interface{}, arg interface{}, condition ...interfa
 	key := memKey(bean, query)
 	bts, err := m.Cache.GetOrStore(key, func() (b []byte, e error) {
 		vb := g2util.NewValue(bean)
-		sn := m.eg.Context(context.Background())
+		sn := eg.Context(context.Background())
 
 		if arg != nil {
 			switch _vv := arg.(type) {
@@ -407,13 +412,35 @@ func (m *Mysql) createDB() (err error) {
 			log.Printf("数据库创建完成,使用数据库: %s\n", m.DbName())
 		}
 	}()
-	createDBSql := fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s;`, m.DbName())
-	return m.ExecSqOnNewEngine(createDBSql)
+	d, err := m.Dialect()
+	if err != nil {
+		return
+	}
+	return m.DialWithMysql(func(x *xorm.Engine) error { return d.CreateDatabase(x, m.DbName()) })
 }
 
 func (m *Mysql) dial() (err error) {
-	dataSource := m.getDataSource()
-	e, err := xorm.NewEngine("mysql", dataSource)
+	dataSource, err := m.getDataSource()
+	if err != nil {
+		return
+	}
+	e, err := m.newEngine(dataSource)
+	if err != nil {
+		return
+	}
+	if err = e.Unscoped().MustLogSQL(false).Ping(); err != nil {
+		return
+	}
+
+	//go g2util.Ticker(time.Second*30, func() { _ = e.Unscoped().MustLogSQL(false).Ping() })
+
+	m.eg = e
+	return
+}
+
+// newEngine ...主库与从库使用相同的配置
+func (m *Mysql) newEngine(dataSource string) (e *xorm.Engine, err error) {
+	e, err = xorm.NewEngine(m.driverName(), dataSource)
 	if err != nil {
 		return
 	}
@@ -447,32 +474,40 @@ func (m *Mysql) dial() (err error) {
 	e.SetConnMaxLifetime(cast.ToDuration(valMap["max_conn_lifetime_seconds"]) * time.Second)
 	e.SetMaxIdleConns(cast.ToInt(valMap["max_idle_connections"]))
 	e.SetMaxOpenConns(cast.ToInt(valMap["max_open_connections"]))
-	if err = e.Unscoped().MustLogSQL(false).Ping(); err != nil {
-		return
-	}
-
-	//go g2util.Ticker(time.Second*30, func() { _ = e.Unscoped().MustLogSQL(false).Ping() })
-
-	m.eg = e
 	return
 }
 
+// driverName ...配置 mysql.driver 为空时为方言的驱动
+func (m *Mysql) driverName() string {
+	if name := m.Config.Viper().GetString("mysql.driver"); len(name) > 0 {
+		return name
+	}
+	if d, err := m.Dialect(); err == nil {
+		return d.DriverName()
+	}
+	return "mysql"
+}
+
 // getDataSource ...
-func (m *Mysql) getDataSource(args ...bool) string {
+func (m *Mysql) getDataSource(args ...bool) (string, error) {
 	//withDB, 当需要将链接db去除时(创建数据库),设置为false
 	withDB := true
 	if len(args) > 0 {
 		withDB = args[0]
 	}
 
-	v := m.Config.Viper()
-	dsn := v.GetString("mysql.dsn")
+	dsn := m.replaceHost(m.Config.Viper().GetString("mysql.dsn"))
 	db := m.DbName()
-	dsn = strings.Replace(dsn, "{host}", v.GetString("global.host"), -1)
-	if withDB {
-		return strings.Replace(dsn, "{db}", db, -1)
+	d, err := m.Dialect()
+	if err != nil {
+		return "", err
 	}
-	return strings.Replace(dsn, "{db}", "", -1)
+	return d.DataSource(dsn, db, withDB), nil
+}
+
+// replaceHost ...替换 dsn 中的 {host}
+func (m *Mysql) replaceHost(dsn string) string {
+	return strings.Replace(dsn, "{host}", m.Config.Viper().GetString("global.host"), -1)
 }
 
 func (m *Mysql) getOut() io.Writer {
@@ -491,14 +526,39 @@ func (m *Mysql) migrate() (err error) {
 	if err = m.dial(); err != nil {
 		return
 	}
+	defer func() { _ = m.eg.Close(); m.eg = nil }()
+	return m.syncMigrate()
+}
+
+// syncMigrate ...已有的数据库先执行版本迁移(重命名字段等), 再由 Sync2 同步, 否则 Sync2 会先按结构体添加新的字段;
+// 新的数据库由 Sync2 创建数据表, 版本迁移只记录不执行(Always 的除外)
+func (m *Mysql) syncMigrate() (err error) {
+	baseline, err := m.freshSchema()
+	if err != nil {
+		return
+	}
+	if baseline {
+		return m.syncBaseline()
+	}
+	if err = m.migrateUp(false, false, nil); err != nil {
+		return
+	}
+	return m.sync()
+}
+
+// syncBaseline ...新的数据库先由 Sync2 创建数据表, 再记录版本并执行 Always 的版本
+func (m *Mysql) syncBaseline() (err error) {
 	if err = m.sync(); err != nil {
 		return
 	}
-	_ = m.eg.Close()
-	return
+	return m.migrateUp(true, false, nil)
 }
 
 func (m *Mysql) sync() (err error) {
+	d, err := m.Dialect()
+	if err != nil {
+		return
+	}
 	return m.TXCallback(func(sn *xorm.Session) (e error) {
 		if len(m.tables) == 0 {
 			return
@@ -508,8 +568,7 @@ func (m *Mysql) sync() (err error) {
 		}
 		for _, table := range m.tables {
 			tbName := tableName(table)
-			sq1 := fmt.Sprintf("ALTER TABLE `%s` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;", tbName)
-			if _, e = sn.Exec(sq1); e != nil {
+			if e = d.Charset(sn, tbName); e != nil {
 				return
 			}
 
@@ -517,7 +576,7 @@ func (m *Mysql) sync() (err error) {
 			if obj, ok := table.(ItfCompoundIndex); ok {
 				for _, c1 := range obj.CompoundIndexes() {
 					c1.SetSn(sn)
-					if e = c1.execCreate(tbName); e != nil {
+					if e = c1.execCreate(d, tbName); e != nil {
 						return fmt.Errorf("[CompoundIndex] [%s] error: %w", tbName, e)
 					}
 				}
@@ -1,32 +1,75 @@
 package g2db
 
 import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"xorm.io/xorm"
+	"xorm.io/xorm/schemas"
 
 	"github.com/atcharles/gof/v2/g2util"
 )
 
+var timeType = reflect.TypeOf(time.Time{})
+
 type (
 	//MysqlQueryRowsParams ...
 	MysqlQueryRowsParams struct {
-		Page        int      `json:"page,omitempty"`
-		PageCount   int      `json:"page_count,omitempty"`
+		Page      int           `json:"page,omitempty"`
+		PageCount int           `json:"page_count,omitempty"`
+		Filters   []MysqlFilter `json:"filters,omitempty"`
+		//原始的 SQL 条件, 只在 Unsafe 时使用, 否则返回错误
 		Conditions  []string `json:"conditions,omitempty"`
 		OrderBy     string   `json:"order_by,omitempty"`
 		Asc         bool     `json:"asc,omitempty"`
 		TimeColumn  string   `json:"time_column"`
 		TimeBetween string   `json:"time_between"`
+		//允许 Conditions 与不在数据表中的字段, 只能在服务端代码中设置, 不要用于客户端传入的参数
+		Unsafe bool `json:"-"`
+		//游标(keyset)分页, 忽略 Page; 第一页不需要 Cursor, 之后使用返回的 next_cursor 或 prev_cursor;
+		//排序字段必须为 notnull 或主键
+		Keyset bool   `json:"keyset,omitempty"`
+		Cursor string `json:"cursor,omitempty"`
+		//不查询总数, 大表中 COUNT(*) 很慢
+		SkipCount bool `json:"skip_count,omitempty"`
+	}
+	//MysqlFilter ...查询条件, Field 必须是数据表的字段, Value 作为参数传递
+	/**
+	{"field": "status", "op": "eq", "value": 1}
+	{"field": "id", "op": "in", "value": [1, 2, 3]}
+	{"field": "name", "op": "like", "value": "%bob%"}
+	{"field": "created", "op": "between", "value": ["2024-01-01", "2024-02-01"]}
+	{"field": "deleted", "op": "null", "value": true}, value 为false时为 IS NOT NULL
+	*/
+	MysqlFilter struct {
+		Field string      `json:"field"`
+		Op    string      `json:"op"`
+		Value interface{} `json:"value,omitempty"`
 	}
 	//MysqlRows ...
 	MysqlRows struct {
 		Pages int         `json:"pages,omitempty"`
 		Data  interface{} `json:"data,omitempty"`
 		Count int64       `json:"count,omitempty"`
+		//游标分页中下一页与上一页的游标, 没有时为空
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}
+	//mysqlCursor ...游标的内容: 排序字段, 方向与最后(上一页时为第一)一行的排序字段与主键的值
+	mysqlCursor struct {
+		OrderBy string      `json:"o"`
+		Asc     bool        `json:"a,omitempty"`
+		Value   interface{} `json:"v"`
+		ID      interface{} `json:"i"`
+		Prev    bool        `json:"p,omitempty"`
 	}
 
 	//ItfMysqlAfterQueryRow ...
@@ -53,10 +96,10 @@ func (q *Query) QueryRows(val interface{}, params *MysqlQueryRowsParams) (rows *
 	sl.Elem().Set(sl1)
 	data := sl.Interface()
 
-	if params.Page == 0 {
+	if params.Page < 1 {
 		params.Page = 1
 	}
-	if params.PageCount == 0 {
+	if params.PageCount < 1 {
 		params.PageCount = 10
 	}
 	const maxCount = 100
@@ -69,9 +112,21 @@ func (q *Query) QueryRows(val interface{}, params *MysqlQueryRowsParams) (rows *
 	if len(params.TimeColumn) == 0 {
 		params.TimeColumn = "created"
 	}
+	if len(params.Conditions) > 0 && !params.Unsafe {
+		err = errors.New("conditions 只能在 Unsafe 模式中使用")
+		return
+	}
+	table, columns, err := q.columns(val)
+	if err != nil {
+		return
+	}
+	if !params.Unsafe && !columns[params.OrderBy] {
+		err = errors.Errorf("无效的排序字段: %s", params.OrderBy)
+		return
+	}
 
 	sq := `SELECT * FROM {{.table}} WHERE ({{.condition}}) ` +
-		`ORDER BY {{.orderBy}} {{.sort}} LIMIT {{.offsetX}},{{.pageCount}}`
+		`ORDER BY {{.orderBy}} {{.sort}} LIMIT {{.pageCount}} OFFSET {{.offsetX}}`
 	tpl := g2util.Map{
 		"orderBy":   db.Quote(params.OrderBy),
 		"sort":      "DESC",
@@ -90,49 +145,334 @@ func (q *Query) QueryRows(val interface{}, params *MysqlQueryRowsParams) (rows *
 	}()
 	tpl["table"] = db.Quote(tableStr)
 
-	condition1 := []string{"1=1"}
-	condition1 = append(condition1, params.Conditions...)
+	filters := params.Filters
 	if len(params.TimeBetween) > 0 {
 		ts := strings.Split(params.TimeBetween, ",")
 		if len(ts) == 2 {
-			condition1 = append(
-				condition1,
-				fmt.Sprintf("(`%s` BETWEEN '%s' AND '%s')", params.TimeColumn, ts[0], ts[1]),
-			)
+			filters = append(filters, MysqlFilter{Field: params.TimeColumn, Op: "between", Value: []string{ts[0], ts[1]}})
+		}
+	}
+	condition1 := []string{"1=1"}
+	condition1 = append(condition1, params.Conditions...)
+	var args []interface{}
+	for _, f := range filters {
+		if !params.Unsafe && !columns[f.Field] {
+			err = errors.Errorf("无效的查询字段: %s", f.Field)
+			return
+		}
+		cond, fArgs, e := f.build(db.Quote(f.Field))
+		if e != nil {
+			err = e
+			return
 		}
+		condition1 = append(condition1, cond)
+		args = append(args, fArgs...)
 	}
 	for i, s := range condition1 {
 		condition1[i] = fmt.Sprintf("(%s)", s)
 	}
 	conditionStr := strings.Join(condition1, " AND ")
 	tpl["condition"] = conditionStr
+	if params.Keyset || len(params.Cursor) > 0 {
+		return q.queryKeyset(val, params, table, tableStr, conditionStr, args)
+	}
 
 	sq = g2util.TextTemplateMustParse(sq, tpl)
-	if err = db.SQL(sq).Find(data); err != nil {
+	if err = db.SQL(sq, args...).Find(data); err != nil {
 		return
 	}
 	rows = &MysqlRows{Data: data}
 	if sl.Elem().Len() == 0 {
 		return
 	}
+	if err = q.count(rows, tableStr, conditionStr, args, params.PageCount); err != nil {
+		return
+	}
+	afterQueryRows(sl.Elem())
+	return
+}
 
-	count, err := db.Table(tableStr).Where(conditionStr).Count()
-	if err != nil {
+// queryKeyset ...按 (排序字段, 主键) 比较上一页的最后一行, 不使用 OFFSET; 多查询一行判断是否还有数据
+func (q *Query) queryKeyset(val interface{}, params *MysqlQueryRowsParams, table *schemas.Table,
+	tableStr, conditionStr string, args []interface{}) (rows *MysqlRows, err error) {
+	db := q.db
+	if len(table.PrimaryKeys) != 1 {
+		err = errors.Errorf("数据表%s的游标分页需要单一主键", tableStr)
+		return
+	}
+	pk, orderCol := table.GetColumn(table.PrimaryKeys[0]), table.GetColumn(params.OrderBy)
+	if orderCol == nil {
+		err = errors.Errorf("无效的排序字段: %s", params.OrderBy)
+		return
+	}
+	//NULL 无法与游标的值比较, 且各数据库中 NULL 的排序位置不同
+	if orderCol.Nullable && orderCol.Name != pk.Name {
+		err = errors.Errorf("游标分页的排序字段%s必须为 notnull", params.OrderBy)
 		return
 	}
+	cur := &mysqlCursor{OrderBy: params.OrderBy, Asc: params.Asc}
+	where, whereArgs := conditionStr, args
+	if len(params.Cursor) > 0 {
+		if cur, err = decodeMysqlCursor(params.Cursor); err != nil {
+			return
+		}
+		if cur.OrderBy != params.OrderBy || cur.Asc != params.Asc {
+			err = errors.New("游标与排序条件不一致")
+			return
+		}
+		//降序时下一页的值更小, 上一页反向查询
+		op := "<"
+		if cur.Asc != cur.Prev {
+			op = ">"
+		}
+		obQ, pkQ := db.Quote(orderCol.Name), db.Quote(pk.Name)
+		whereArgs = append([]interface{}(nil), args...)
+		if orderCol.Name == pk.Name {
+			where += fmt.Sprintf(" AND (%s %s ?)", pkQ, op)
+			whereArgs = append(whereArgs, cur.ID)
+		} else {
+			where += fmt.Sprintf(" AND (%s %s ? OR (%s = ? AND %s %s ?))", obQ, op, obQ, pkQ, op)
+			whereArgs = append(whereArgs, cur.Value, cur.Value, cur.ID)
+		}
+	}
+	sort := "DESC"
+	if params.Asc != cur.Prev {
+		sort = "ASC"
+	}
+	orderBy := fmt.Sprintf("%s %s", db.Quote(orderCol.Name), sort)
+	if orderCol.Name != pk.Name {
+		orderBy += fmt.Sprintf(", %s %s", db.Quote(pk.Name), sort)
+	}
+	sq := fmt.Sprintf("SELECT * FROM %s WHERE (%s) ORDER BY %s LIMIT %d",
+		db.Quote(tableStr), where, orderBy, params.PageCount+1)
+
+	sl := reflect.New(reflect.SliceOf(reflect.TypeOf(val)))
+	if err = db.SQL(sq, whereArgs...).Find(sl.Interface()); err != nil {
+		return
+	}
+	list := sl.Elem()
+	hasMore := list.Len() > params.PageCount
+	if hasMore {
+		list.Set(list.Slice(0, params.PageCount))
+	}
+	if cur.Prev {
+		swap := reflect.Swapper(list.Interface())
+		for i, j := 0, list.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	rows = &MysqlRows{Data: sl.Interface()}
+	if list.Len() == 0 {
+		return
+	}
+	//向前翻页时后面总有数据, 向后翻页时前面总有数据
+	if hasMore || cur.Prev {
+		if rows.NextCursor, err = q.cursor(params, pk, orderCol, list.Index(list.Len()-1), false); err != nil {
+			return
+		}
+	}
+	if (hasMore && cur.Prev) || (!cur.Prev && len(params.Cursor) > 0) {
+		if rows.PrevCursor, err = q.cursor(params, pk, orderCol, list.Index(0), true); err != nil {
+			return
+		}
+	}
+	if !params.SkipCount {
+		if err = q.count(rows, tableStr, conditionStr, args, params.PageCount); err != nil {
+			return
+		}
+	}
+	afterQueryRows(list)
+	return
+}
+
+// cursor ...编码一行的排序字段与主键的值
+func (q *Query) cursor(params *MysqlQueryRowsParams, pk, orderCol *schemas.Column, row reflect.Value,
+	prev bool) (string, error) {
+	bean := row.Interface()
+	cur := &mysqlCursor{OrderBy: params.OrderBy, Asc: params.Asc, Prev: prev}
+	for _, item := range []struct {
+		col *schemas.Column
+		dst *interface{}
+	}{{orderCol, &cur.Value}, {pk, &cur.ID}} {
+		value, err := item.col.ValueOf(bean)
+		if err != nil {
+			return "", err
+		}
+		if *item.dst, err = q.cursorValue(*value); err != nil {
+			return "", err
+		}
+		if *item.dst == nil {
+			return "", errors.Errorf("游标分页的字段%s的值为 NULL", item.col.Name)
+		}
+	}
+	bts, err := json.Marshal(cur)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bts), nil
+}
+
+// cursorValue ...时间(包括可以转换为 time.Time 的类型, 如 g2util.JSONTime)按数据库的时区格式化, 与字段直接比较;
+// 其他实现了 driver.Valuer 的类型使用 Value 的结果
+func (q *Query) cursorValue(v reflect.Value) (interface{}, error) {
+	v = g2util.ValueIndirect(v)
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type().ConvertibleTo(timeType) {
+		return q.formatTime(v.Convert(timeType).Interface().(time.Time)), nil
+	}
+	val := v.Interface()
+	if v.CanAddr() {
+		if _, ok := val.(driver.Valuer); !ok {
+			val = v.Addr().Interface()
+		}
+	}
+	if valuer, ok := val.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		switch dv := dv.(type) {
+		case time.Time:
+			return q.formatTime(dv), nil
+		case []byte:
+			return string(dv), nil
+		default:
+			return dv, nil
+		}
+	}
+	return v.Interface(), nil
+}
+
+// formatTime ...数据库的时区
+func (q *Query) formatTime(t time.Time) string {
+	loc := q.db.DatabaseTZ
+	if loc == nil {
+		loc = time.Local
+	}
+	return t.In(loc).Format("2006-01-02 15:04:05.999999")
+}
+
+// decodeMysqlCursor ...数字使用 json.Number 解码, 避免大的主键丢失精度
+func decodeMysqlCursor(s string) (cur *mysqlCursor, err error) {
+	bts, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("无效的游标")
+	}
+	cur = new(mysqlCursor)
+	dec := json.NewDecoder(bytes.NewReader(bts))
+	dec.UseNumber()
+	if err = dec.Decode(cur); err != nil {
+		return nil, errors.New("无效的游标")
+	}
+	for _, v := range []*interface{}{&cur.Value, &cur.ID} {
+		switch n := (*v).(type) {
+		case json.Number:
+			if i, e := n.Int64(); e == nil {
+				*v = i
+			} else if f, e := n.Float64(); e == nil {
+				*v = f
+			}
+		case map[string]interface{}, []interface{}:
+			return nil, errors.New("无效的游标")
+		}
+	}
+	return
+}
+
+// count ...总数与页数
+func (q *Query) count(rows *MysqlRows, tableStr, conditionStr string, args []interface{}, pageCount int) error {
+	count, err := q.db.Table(tableStr).Where(conditionStr, args...).Count()
+	if err != nil {
+		return err
+	}
 	rows.Count = count
-	rows.Pages = int(count) / params.PageCount
-	if int(count)%params.PageCount > 0 {
+	rows.Pages = int(count) / pageCount
+	if int(count)%pageCount > 0 {
 		rows.Pages++
 	}
+	return nil
+}
 
-	ss1 := sl.Elem()
-	for i := 0; i < ss1.Len(); i++ {
-		switch vv := ss1.Index(i).Interface().(type) {
+// afterQueryRows ...
+func afterQueryRows(list reflect.Value) {
+	for i := 0; i < list.Len(); i++ {
+		switch vv := list.Index(i).Interface().(type) {
 		case ItfMysqlAfterQueryRow:
 			vv.MysqlAfterQueryRow()
 		}
 	}
+}
+
+// columns ...数据表的字段名称
+func (q *Query) columns(val interface{}) (table *schemas.Table, columns map[string]bool, err error) {
+	if table, err = q.db.TableInfo(val); err != nil {
+		return
+	}
+	columns = make(map[string]bool)
+	for _, col := range table.Columns() {
+		columns[col.Name] = true
+	}
+	return
+}
+
+// build ...参数化的条件, column 为已经转义的字段名
+func (f *MysqlFilter) build(column string) (cond string, args []interface{}, err error) {
+	switch f.Op {
+	case "eq", "ne", "like":
+		if f.Value == nil {
+			err = errors.Errorf("%s 的 %s 条件缺少 value", f.Field, f.Op)
+			return
+		}
+		if v := reflect.ValueOf(f.Value); v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+			err = errors.Errorf("%s 的 %s 条件的 value 必须是单个值", f.Field, f.Op)
+			return
+		}
+		op := map[string]string{"eq": "=", "ne": "<>", "like": "LIKE"}[f.Op]
+		return fmt.Sprintf("%s %s ?", column, op), []interface{}{f.Value}, nil
+	case "in":
+		if args = filterValues(f.Value); len(args) == 0 {
+			err = errors.Errorf("%s 的 in 条件的 value 必须是非空数组", f.Field)
+			return
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+		return fmt.Sprintf("%s IN (%s)", column, marks), args, nil
+	case "between":
+		if args = filterValues(f.Value); len(args) != 2 {
+			err = errors.Errorf("%s 的 between 条件的 value 必须是两个值的数组", f.Field)
+			return
+		}
+		return fmt.Sprintf("%s BETWEEN ? AND ?", column), args, nil
+	case "null":
+		if isNull, ok := f.Value.(bool); ok && !isNull {
+			return fmt.Sprintf("%s IS NOT NULL", column), nil, nil
+		}
+		return fmt.Sprintf("%s IS NULL", column), nil, nil
+	default:
+		err = errors.Errorf("%s 的条件 %s 不支持", f.Field, f.Op)
+		return
+	}
+}
+
+// filterValues ...数组的元素, 不是数组或元素不是单个值时返回nil
+func filterValues(value interface{}) (list []interface{}) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil
+	}
+	list = make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		for (item.Kind() == reflect.Interface || item.Kind() == reflect.Ptr) && !item.IsNil() {
+			item = item.Elem()
+		}
+		if item.Kind() == reflect.Interface || item.Kind() == reflect.Ptr ||
+			item.Kind() == reflect.Slice || item.Kind() == reflect.Map {
+			return nil
+		}
+		list = append(list, item.Interface())
+	}
 	return
 }
 
@@ -156,16 +496,28 @@ func (m *Mysql) GetBeanByTableName(tableStr string) (bean interface{}, err error
 // NewQuery ...
 func NewQuery(engine *xorm.Engine) *Query { return &Query{db: engine} }
 
-// QueryRows ...分页查询,可以指定表名
+// QueryRows ...分页查询,可以指定表名; 从从库读取
 func (m *Mysql) QueryRows(val interface{}, params *MysqlQueryRowsParams) (rows *MysqlRows, err error) {
-	return NewQuery(m.Engine()).QueryRows(val, params)
+	return m.QueryRowsContext(context.Background(), val, params)
 }
 
-// QueryTableRows ...查询经过注册的表
+// QueryRowsContext ...请求中写入过数据时从主库读取
+func (m *Mysql) QueryRowsContext(ctx context.Context, val interface{}, params *MysqlQueryRowsParams) (
+	rows *MysqlRows, err error) {
+	return NewQuery(m.ReadEngine(ctx)).QueryRows(val, params)
+}
+
+// QueryTableRows ...查询经过注册的表; 从从库读取
 func (m *Mysql) QueryTableRows(tableStr string, params *MysqlQueryRowsParams) (rows *MysqlRows, err error) {
+	return m.QueryTableRowsContext(context.Background(), tableStr, params)
+}
+
+// QueryTableRowsContext ...请求中写入过数据时从主库读取
+func (m *Mysql) QueryTableRowsContext(ctx context.Context, tableStr string, params *MysqlQueryRowsParams) (
+	rows *MysqlRows, err error) {
 	val, err := m.GetBeanByTableName(tableStr)
 	if err != nil {
 		return
 	}
-	return m.QueryRows(val, params)
+	return m.QueryRowsContext(ctx, val, params)
 }
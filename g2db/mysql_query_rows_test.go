@@ -0,0 +1,198 @@
+package g2db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// keysetRows ...按 id 返回 test_time_row 的行, created 为 2024-05-01 12:30:0{id}
+func keysetRows(ids ...int64) (cols []string, rows [][]driver.Value) {
+	cols = []string{"id", "created", "updated", "version", "name", "expired"}
+	for _, id := range ids {
+		created := []byte(fmt.Sprintf("2024-05-01 12:30:%02d", id))
+		rows = append(rows, []driver.Value{id, created, created, int64(1), "n", nil})
+	}
+	return
+}
+
+// rowIDs ...
+func rowIDs(rows *MysqlRows) (ids []int64) {
+	for _, row := range *rows.Data.(*[]*testTimeRow) {
+		ids = append(ids, row.ID)
+	}
+	return
+}
+
+func TestQueryRowsKeyset(t *testing.T) {
+	db, eg := newTestEngine(t, "root:1@tcp(keyset)/app")
+	query := func(cursor string, ids ...int64) (*MysqlRows, string, []driver.Value) {
+		t.Helper()
+		db.query = func(string, []driver.Value) ([]string, [][]driver.Value) { return keysetRows(ids...) }
+		params := &MysqlQueryRowsParams{OrderBy: "created", Keyset: true, Cursor: cursor, PageCount: 2, SkipCount: true}
+		rows, err := NewQuery(eg).QueryRows(new(testTimeRow), params)
+		if err != nil {
+			t.Fatalf("cursor %q: %v", cursor, err)
+		}
+		list := db.statements()
+		return rows, list[len(list)-1], db.lastArgs()
+	}
+
+	page1, sq, _ := query("", 5, 4, 3)
+	if ids := rowIDs(page1); !reflect.DeepEqual(ids, []int64{5, 4}) || len(page1.NextCursor) == 0 ||
+		len(page1.PrevCursor) > 0 {
+		t.Fatalf("page 1: ids %v next %q prev %q", ids, page1.NextCursor, page1.PrevCursor)
+	}
+	if !strings.Contains(sq, "ORDER BY `created` DESC, `id` DESC LIMIT 3") {
+		t.Errorf("page 1 sql: %s", sq)
+	}
+
+	page2, sq, args := query(page1.NextCursor, 3, 2, 1)
+	if ids := rowIDs(page2); !reflect.DeepEqual(ids, []int64{3, 2}) || len(page2.PrevCursor) == 0 {
+		t.Fatalf("page 2: ids %v prev %q", ids, page2.PrevCursor)
+	}
+	if !strings.Contains(sq, "(`created` < ? OR (`created` = ? AND `id` < ?))") {
+		t.Errorf("page 2 sql: %s", sq)
+	}
+	if want := []driver.Value{"2024-05-01 12:30:04", "2024-05-01 12:30:04", int64(4)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("page 2 args = %v, want %v", args, want)
+	}
+
+	//上一页反向查询, 结果恢复为原来的顺序
+	back, sq, args := query(page2.PrevCursor, 4, 5)
+	if ids := rowIDs(back); !reflect.DeepEqual(ids, []int64{5, 4}) || len(back.NextCursor) == 0 {
+		t.Fatalf("back: ids %v next %q", ids, back.NextCursor)
+	}
+	if !strings.Contains(sq, "(`created` > ? OR (`created` = ? AND `id` > ?))) ORDER BY `created` ASC") {
+		t.Errorf("back sql: %s", sq)
+	}
+	if want := []driver.Value{"2024-05-01 12:30:03", "2024-05-01 12:30:03", int64(3)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("back args = %v, want %v", args, want)
+	}
+}
+
+func TestQueryRowsKeysetInvalid(t *testing.T) {
+	db, eg := newTestEngine(t, "root:1@tcp(keyset_invalid)/app")
+	tests := []struct {
+		params *MysqlQueryRowsParams
+		err    string
+	}{
+		{&MysqlQueryRowsParams{OrderBy: "name", Keyset: true}, "notnull"},
+		{&MysqlQueryRowsParams{OrderBy: "created", Cursor: "!"}, "无效的游标"},
+		{&MysqlQueryRowsParams{OrderBy: "id", Asc: true, Cursor: "eyJvIjoiY3JlYXRlZCIsInYiOjEsImkiOjF9"}, "不一致"},
+	}
+	for _, tt := range tests {
+		_, err := NewQuery(eg).QueryRows(new(testTimeRow), tt.params)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%+v: error %v, want %q", tt.params, err, tt.err)
+		}
+	}
+
+	//小于1的页码与每页数量使用默认值
+	db.query = func(q string, _ []driver.Value) ([]string, [][]driver.Value) {
+		if strings.HasPrefix(q, "SELECT count(") {
+			return []string{"count"}, [][]driver.Value{{int64(3)}}
+		}
+		return keysetRows(3, 2, 1)
+	}
+	clamps := []struct {
+		params *MysqlQueryRowsParams
+		sql    string
+	}{
+		{&MysqlQueryRowsParams{OrderBy: "created", Keyset: true, PageCount: -1}, "LIMIT 11"},
+		{&MysqlQueryRowsParams{Page: -2, PageCount: -1}, "LIMIT 10 OFFSET 0"},
+		{&MysqlQueryRowsParams{Page: 0, PageCount: 0}, "LIMIT 10 OFFSET 0"},
+	}
+	for _, tt := range clamps {
+		if _, err := NewQuery(eg).QueryRows(new(testTimeRow), tt.params); err != nil {
+			t.Errorf("%+v: %v", tt.params, err)
+			continue
+		}
+		if list := db.statements(); !hasStatement(list[len(list)-2:], tt.sql) {
+			t.Errorf("%+v: sql %q, want %s", tt.params, list[len(list)-2:], tt.sql)
+		}
+	}
+}
+
+// TestQueryRowsFilters ...条件的值作为参数传递, 不拼接到 SQL 中
+func TestQueryRowsFilters(t *testing.T) {
+	db, eg := newTestEngine(t, "root:1@tcp(filters)/app")
+	tests := []struct {
+		filter MysqlFilter
+		cond   string
+		args   []driver.Value
+	}{
+		{MysqlFilter{Field: "name", Op: "eq", Value: "x' OR '1'='1"}, "(`name` = ?)", []driver.Value{"x' OR '1'='1"}},
+		{MysqlFilter{Field: "version", Op: "ne", Value: 2}, "(`version` <> ?)", []driver.Value{int64(2)}},
+		{MysqlFilter{Field: "name", Op: "like", Value: "%bob%"}, "(`name` LIKE ?)", []driver.Value{"%bob%"}},
+		{MysqlFilter{Field: "id", Op: "in", Value: []interface{}{1, 2, 3}}, "(`id` IN (?,?,?))",
+			[]driver.Value{int64(1), int64(2), int64(3)}},
+		{MysqlFilter{Field: "created", Op: "between", Value: []string{"2024-01-01", "2024-02-01"}},
+			"(`created` BETWEEN ? AND ?)", []driver.Value{"2024-01-01", "2024-02-01"}},
+		{MysqlFilter{Field: "expired", Op: "null", Value: true}, "(`expired` IS NULL)", nil},
+		{MysqlFilter{Field: "expired", Op: "null", Value: false}, "(`expired` IS NOT NULL)", nil},
+	}
+	for _, tt := range tests {
+		params := &MysqlQueryRowsParams{Filters: []MysqlFilter{tt.filter}}
+		if _, err := NewQuery(eg).QueryRows(new(testTimeRow), params); err != nil {
+			t.Errorf("%+v: %v", tt.filter, err)
+			continue
+		}
+		list := db.statements()
+		if sq := list[len(list)-1]; !strings.Contains(sq, "WHERE ((1=1) AND "+tt.cond+")") {
+			t.Errorf("%+v: sql %s, want %s", tt.filter, sq, tt.cond)
+		}
+		if args := db.lastArgs(); !reflect.DeepEqual(args, tt.args) && (len(args) > 0 || len(tt.args) > 0) {
+			t.Errorf("%+v: args %v, want %v", tt.filter, args, tt.args)
+		}
+	}
+
+	params := &MysqlQueryRowsParams{TimeBetween: "2024-01-01,2024-02-01"}
+	if _, err := NewQuery(eg).QueryRows(new(testTimeRow), params); err != nil {
+		t.Fatal(err)
+	}
+	list := db.statements()
+	if sq := list[len(list)-1]; !strings.Contains(sq, "(`created` BETWEEN ? AND ?)") {
+		t.Errorf("time_between: sql %s", sq)
+	}
+}
+
+// TestQueryRowsRejected ...不在数据表中的字段, 不支持的条件与原始 SQL(没有 Unsafe)返回错误, 不执行查询
+func TestQueryRowsRejected(t *testing.T) {
+	db, eg := newTestEngine(t, "root:1@tcp(rejected)/app")
+	tests := []struct {
+		params *MysqlQueryRowsParams
+		err    string
+	}{
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "nope", Op: "eq", Value: 1}}}, "无效的查询字段"},
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "id = 1 OR 1", Op: "eq", Value: 1}}}, "无效的查询字段"},
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "id", Op: "gt", Value: 1}}}, "不支持"},
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "id", Op: "eq"}}}, "缺少 value"},
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "id", Op: "eq", Value: []int{1}}}}, "单个值"},
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "id", Op: "in", Value: []int{}}}}, "非空数组"},
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "id", Op: "in", Value: [][]int{{1}}}}}, "非空数组"},
+		{&MysqlQueryRowsParams{Filters: []MysqlFilter{{Field: "id", Op: "between", Value: []int{1}}}}, "两个值"},
+		{&MysqlQueryRowsParams{OrderBy: "id desc; drop"}, "无效的排序字段"},
+		{&MysqlQueryRowsParams{Conditions: []string{"1=1) OR (1=1"}}, "Unsafe"},
+	}
+	for _, tt := range tests {
+		_, err := NewQuery(eg).QueryRows(new(testTimeRow), tt.params)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%+v: error %v, want %q", tt.params, err, tt.err)
+		}
+	}
+	if list := db.statements(); len(list) > 0 {
+		t.Errorf("rejected queries executed: %q", list)
+	}
+
+	//服务端代码设置 Unsafe 时允许原始 SQL 条件
+	params := &MysqlQueryRowsParams{Conditions: []string{"`version` > 1"}, Unsafe: true}
+	if _, err := NewQuery(eg).QueryRows(new(testTimeRow), params); err != nil {
+		t.Fatal(err)
+	}
+	if list := db.statements(); len(list) != 1 || !strings.Contains(list[0], "((1=1) AND (`version` > 1))") {
+		t.Errorf("unsafe conditions: %q", list)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/cast"
 
 	"github.com/atcharles/gof/v2/g2util"
+	"github.com/atcharles/gof/v2/j2rpc"
 	"github.com/atcharles/gof/v2/json"
 )
 
@@ -19,6 +20,7 @@ const (
 	redisSubChannel     = "Sub"
 	redisSubDelMemCache = "DelMemCache"
 	redisSubDelMemAll   = "DelMemAll"
+	redisSubJ2rpcCache  = "J2rpcCache"
 )
 
 type (
@@ -74,6 +76,26 @@ func (r *redisObj) PubDelCache(keys []string) error { return r.Pub(r.pubDelMemNa
 // PubDelMemAll ...
 func (r *redisObj) PubDelMemAll() error { return r.Pub(r.formatWithAppName(redisSubDelMemAll), nil) }
 
+// PubJ2rpcCacheInvalidate ...通知所有实例清除 j2rpc 的结果缓存, names 为方法或命名空间, 为空时清除全部
+func (r *redisObj) PubJ2rpcCacheInvalidate(names ...string) error {
+	return r.Pub(r.formatWithAppName(redisSubJ2rpcCache), names)
+}
+
+// SubJ2rpcCache ...接收 PubJ2rpcCacheInvalidate 的通知, 在 Subscribe 之后调用
+func (r *redisObj) SubJ2rpcCache(jsv j2rpc.RPCServer) {
+	r.SubHandle(r.formatWithAppName(redisSubJ2rpcCache), func(payload []byte) {
+		names := make([]string, 0)
+		if len(payload) > 0 {
+			if e := json.Unmarshal(payload, &names); e != nil {
+				r.Logger.Errorf("[SUB] 无效的j2rpc缓存通知: %s", payload)
+				return
+			}
+		}
+		jsv.InvalidateCache(names...)
+		r.Logger.Debugf("[SUB] 清除j2rpc缓存: %v", names)
+	})
+}
+
 func (r *redisObj) SubHandle(name string, handler RedisSubHandlerFunc) { r.subHandlers[name] = handler }
 
 // Subscribe ...
@@ -0,0 +1,155 @@
+package g2db
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// ContextReadYourWritesKey ...请求的 context 中 *ReadYourWrites 的 key, g2gin 在每个请求中设置
+const ContextReadYourWritesKey = "DB_READ_YOUR_WRITES"
+
+type (
+	//ReadYourWrites ...请求中写入数据之后, 同一个请求的读取使用主库, 避免从库的复制延迟读到旧的数据
+	ReadYourWrites struct{ written int32 }
+
+	//replica ...只读从库, down 为健康检查失败, 不参与轮询
+	replica struct {
+		index int
+		eg    *xorm.Engine
+		down  int32
+	}
+)
+
+// NewReadYourWrites ...
+func NewReadYourWrites() *ReadYourWrites { return new(ReadYourWrites) }
+
+// WithReadYourWrites ...非 gin 的请求(任务, 消息队列等)使用, ctx 中已经存在时返回 ctx
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if readYourWrites(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, ContextReadYourWritesKey, NewReadYourWrites())
+}
+
+// MarkWritten ...标记请求中写入过数据, 之后的读取使用主库; 只有 TXCallbackContext 会自动标记,
+// Insert, Update, Delete, TXCallback 与 Session 的写入没有 context, 需要手动标记
+func MarkWritten(ctx context.Context) {
+	if r := readYourWrites(ctx); r != nil {
+		atomic.StoreInt32(&r.written, 1)
+	}
+}
+
+// readYourWrites ...
+func readYourWrites(ctx context.Context) *ReadYourWrites {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(ContextReadYourWritesKey).(*ReadYourWrites)
+	return r
+}
+
+// ReadEngine ...读取使用的数据库: 请求中写入过数据时为主库, 否则轮询健康的从库, 没有可用的从库时为主库
+func (m *Mysql) ReadEngine(ctx context.Context) *xorm.Engine {
+	if r := readYourWrites(ctx); r != nil && atomic.LoadInt32(&r.written) == 1 {
+		return m.eg
+	}
+	m.mu.RLock()
+	replicas := m.replicas
+	m.mu.RUnlock()
+	n := len(replicas)
+	if n == 0 {
+		return m.eg
+	}
+	next := int(atomic.AddUint64(&m.replicaNext, 1) % uint64(n))
+	for i := 0; i < n; i++ {
+		if r := replicas[(next+i)%n]; atomic.LoadInt32(&r.down) == 0 {
+			return r.eg
+		}
+	}
+	return m.eg
+}
+
+// TXCallbackContext ...主库的事务, 并标记请求中写入过数据
+func (m *Mysql) TXCallbackContext(ctx context.Context, fn func(sn *xorm.Session) (err error)) (err error) {
+	MarkWritten(ctx)
+	return m.TXCallback(fn)
+}
+
+// dialReplicas ...连接配置 mysql.replicas 中的从库, 连接失败的从库由健康检查恢复
+func (m *Mysql) dialReplicas() (err error) {
+	v := m.Config.Viper()
+	list := v.GetStringSlice("mysql.replicas")
+	if len(list) == 0 {
+		return
+	}
+	d, err := m.Dialect()
+	if err != nil {
+		return
+	}
+	replicas := make([]*replica, 0, len(list))
+	for i, dsn := range list {
+		e, err := m.newEngine(d.DataSource(m.replaceHost(dsn), m.DbName(), true))
+		if err != nil {
+			return err
+		}
+		r := &replica{index: i, eg: e}
+		if err = e.Unscoped().MustLogSQL(false).Ping(); err != nil {
+			log.Printf("[Replica] %d 连接失败: %s\n", i, err.Error())
+			r.down = 1
+		}
+		replicas = append(replicas, r)
+	}
+	m.mu.Lock()
+	m.replicas = replicas
+	m.replicaStop = make(chan struct{})
+	m.mu.Unlock()
+
+	interval := time.Duration(v.GetInt("mysql.replica_check_seconds")) * time.Second
+	if interval <= 0 {
+		interval = time.Second * 5
+	}
+	go m.checkReplicas(replicas, interval, m.replicaStop)
+	return
+}
+
+// checkReplicas ...定时 ping 从库, 失败时从轮询中移除, 恢复后重新加入
+func (m *Mysql) checkReplicas(replicas []*replica, interval time.Duration, stop chan struct{}) {
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tk.C:
+		}
+		for _, r := range replicas {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := r.eg.Unscoped().MustLogSQL(false).PingContext(ctx)
+			cancel()
+			switch {
+			case err != nil && atomic.CompareAndSwapInt32(&r.down, 0, 1):
+				log.Printf("[Replica] %d 不可用, 从读取中移除: %s\n", r.index, err.Error())
+			case err == nil && atomic.CompareAndSwapInt32(&r.down, 1, 0):
+				log.Printf("[Replica] %d 已恢复\n", r.index)
+			}
+		}
+	}
+}
+
+// closeReplicas ...
+func (m *Mysql) closeReplicas() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.replicaStop != nil {
+		close(m.replicaStop)
+		m.replicaStop = nil
+	}
+	for _, r := range m.replicas {
+		_ = r.eg.Close()
+	}
+	m.replicas = nil
+}
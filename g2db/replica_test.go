@@ -0,0 +1,222 @@
+package g2db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"xorm.io/xorm"
+
+	"github.com/atcharles/gof/v2/g2cache/store"
+)
+
+// mapStore ...内存中的 store.ItfCache
+type mapStore struct {
+	mu sync.Mutex
+	mp map[string][]byte
+}
+
+func (*mapStore) String() string { return "map" }
+
+func (s *mapStore) Set(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mp[key] = data
+	return nil
+}
+
+func (s *mapStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.mp[key]; ok {
+		return v, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *mapStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.mp, key)
+	return nil
+}
+
+func (s *mapStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mp = make(map[string][]byte)
+	return nil
+}
+
+func (s *mapStore) CacheInstance() store.ItfCache { return s }
+
+// newReplicaTest ...一个主库与两个从库
+func newReplicaTest(t *testing.T, name string) (m *Mysql, primary *testDB, replicas []*testDB) {
+	primary, eg := newTestEngine(t, "root:1@tcp(primary)/"+name)
+	m = &Mysql{eg: eg, Cache: &cacheMem{Cache: &mapStore{mp: make(map[string][]byte)}}, CacheBind: new(cacheBind)}
+	for i := 0; i < 2; i++ {
+		db, e := newTestEngine(t, fmt.Sprintf("root:1@tcp(replica%d)/%s", i, name))
+		replicas = append(replicas, db)
+		m.replicas = append(m.replicas, &replica{index: i, eg: e})
+	}
+	return
+}
+
+// selects ...查询 test_time_row 的次数
+func selects(db *testDB) (n int) {
+	for _, s := range db.statements() {
+		if strings.HasPrefix(s, "SELECT") && strings.Contains(s, "test_time_row") {
+			n++
+		}
+	}
+	return
+}
+
+func TestReadEngineRouting(t *testing.T) {
+	m, primary, replicas := newReplicaTest(t, "routing")
+	query := func(ctx context.Context) {
+		t.Helper()
+		params := &MysqlQueryRowsParams{SkipCount: true}
+		var err error
+		if ctx == nil {
+			_, err = m.QueryRows(new(testTimeRow), params)
+		} else {
+			_, err = m.QueryRowsContext(ctx, new(testTimeRow), params)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() [3]int { return [3]int{selects(primary), selects(replicas[0]), selects(replicas[1])} }
+
+	for i := 0; i < 4; i++ {
+		query(nil)
+	}
+	if got := count(); got != [3]int{0, 2, 2} {
+		t.Fatalf("QueryRows: primary/replicas = %v, want round robin over the replicas", got)
+	}
+	query(context.Background())
+	query(context.Background())
+	if got := count(); got != [3]int{0, 3, 3} {
+		t.Fatalf("QueryRowsContext: primary/replicas = %v, want round robin over the replicas", got)
+	}
+
+	atomic.StoreInt32(&m.replicas[0].down, 1)
+	query(nil)
+	query(nil)
+	if got := count(); got != [3]int{0, 3, 5} {
+		t.Fatalf("replica 0 down: primary/replicas = %v", got)
+	}
+
+	atomic.StoreInt32(&m.replicas[1].down, 1)
+	query(nil)
+	if got := count(); got != [3]int{1, 3, 5} {
+		t.Fatalf("all replicas down: primary/replicas = %v, want the primary", got)
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	m, primary, replicas := newReplicaTest(t, "read_your_writes")
+	ctx := WithReadYourWrites(context.Background())
+	if WithReadYourWrites(ctx) != ctx {
+		t.Error("WithReadYourWrites replaced the existing value")
+	}
+	other := WithReadYourWrites(context.Background())
+
+	if eg := m.ReadEngine(ctx); eg == m.eg {
+		t.Fatal("read before writing used the primary")
+	}
+	if err := m.TXCallbackContext(ctx, func(sn *xorm.Session) error {
+		_, e := sn.Exec("UPDATE test_time_row SET name = 'x'")
+		return e
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasStatement(primary.statements(), "UPDATE test_time_row") {
+		t.Fatal("write not sent to the primary")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := m.QueryRowsContext(ctx, new(testTimeRow), &MysqlQueryRowsParams{SkipCount: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := selects(primary); n != 3 || selects(replicas[0])+selects(replicas[1]) != 0 {
+		t.Errorf("reads after writing: primary %d, replicas %d, want all on the primary", n,
+			selects(replicas[0])+selects(replicas[1]))
+	}
+	if eg := m.ReadEngine(other); eg == m.eg {
+		t.Error("another request read from the primary")
+	}
+
+	ctx = WithReadYourWrites(context.Background())
+	MarkWritten(ctx)
+	if eg := m.ReadEngine(ctx); eg != m.eg {
+		t.Error("MarkWritten: read not sent to the primary")
+	}
+}
+
+// TestCacheGetReplica ...缓存不存在时从从库读取, 请求中写入过数据时从主库读取
+func TestCacheGetReplica(t *testing.T) {
+	m, primary, replicas := newReplicaTest(t, "cache_get")
+	rows := func(string, []driver.Value) ([]string, [][]driver.Value) { return keysetRows(1) }
+	primary.query, replicas[0].query, replicas[1].query = rows, rows, rows
+	get := func(ctx context.Context, id int64) {
+		t.Helper()
+		row := new(testTimeRow)
+		row.ID = id
+		var err error
+		if ctx == nil {
+			err = m.CacheGet(row)
+		} else {
+			err = m.CacheGetContext(ctx, row)
+		}
+		if err != nil || row.Name != "n" {
+			t.Fatalf("CacheGet %d: name %q, %v", id, row.Name, err)
+		}
+	}
+	get(nil, 1)
+	get(nil, 1)
+	if n, r := selects(primary), selects(replicas[0])+selects(replicas[1]); n != 0 || r != 1 {
+		t.Errorf("CacheGet: primary %d replicas %d, want one replica query then cached", n, r)
+	}
+
+	ctx := WithReadYourWrites(context.Background())
+	MarkWritten(ctx)
+	get(ctx, 2)
+	if n := selects(primary); n != 1 {
+		t.Errorf("CacheGetContext after writing: primary queried %d times, want 1", n)
+	}
+}
+
+func TestCheckReplicas(t *testing.T) {
+	m, _, replicas := newReplicaTest(t, "check")
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.checkReplicas(m.replicas, time.Millisecond*5, stop)
+
+	wait := func(down int32) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt32(&m.replicas[0].down) != down {
+			if time.Now().After(deadline) {
+				t.Fatalf("replica 0 down = %d, want %d", atomic.LoadInt32(&m.replicas[0].down), down)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	replicas[0].setDown(true)
+	wait(1)
+	if eg := m.ReadEngine(context.Background()); eg != m.replicas[1].eg {
+		t.Error("read sent to the unhealthy replica")
+	}
+	replicas[0].setDown(false)
+	wait(0)
+	if atomic.LoadInt32(&m.replicas[1].down) != 0 {
+		t.Error("healthy replica marked down")
+	}
+}
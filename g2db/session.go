@@ -19,7 +19,7 @@ func (s *Session) Delete(bean interface{}) (err error) {
 	if len(queryList) == 0 {
 		return
 	}
-	if err = s.mysql.CacheGet(bean); err != nil {
+	if err = s.cacheGet(bean); err != nil {
 		return
 	}
 	if v, ok := bean.(ItfSessionBeforeDelete); ok {
@@ -72,7 +72,7 @@ func (s *Session) Update(bean interface{}, params ...interface{}) (newBean inter
 	if err != nil {
 		return
 	}
-	if err = s.mysql.CacheGet(newBean); err != nil {
+	if err = s.cacheGet(newBean); err != nil {
 		return
 	}
 
@@ -141,6 +141,11 @@ func (s *Session) Update(bean interface{}, params ...interface{}) (newBean inter
 	return
 }
 
+// cacheGet ...写入之前的读取使用主库, 从库的复制延迟可能读到旧的数据
+func (s *Session) cacheGet(bean interface{}) error {
+	return s.mysql.cacheGet(s.mysql.eg, bean, []string{"Unscoped"})
+}
+
 // newSession ...
 func newSession(mysql *Mysql, sn *xorm.Session) *Session {
 	return &Session{mysql: mysql, sn: sn}
@@ -62,6 +62,37 @@ func (t *Token) Constructor() {
 	}
 }
 
+// J2rpcAuthenticator ...作为 j2rpc.Option.Authenticator, 校验令牌; roles 根据用户id返回角色, 为nil时只校验登录
+func (t *Token) J2rpcAuthenticator(roles func(ctx context.Context, id int64) ([]string, error)) j2rpc.Authenticator {
+	return func(ctx context.Context, _ string) (list []string, err error) {
+		c, ok := ctx.(ItfGinContext)
+		if !ok {
+			return nil, j2rpc.TokenError("非法访问")
+		}
+		if err = t.Verify(c); err != nil || roles == nil {
+			return
+		}
+		if list, err = roles(c, cast.ToInt64(c.Value(GinContextJWTUIDKey))); err != nil {
+			return nil, j2rpc.NewError(j2rpc.ErrServer, err.Error())
+		}
+		return
+	}
+}
+
+// J2rpcUser ...作为 j2rpc.IdempotencyOption.User 等, 返回请求的用户id; 令牌无效时返回空
+func (t *Token) J2rpcUser() func(ctx context.Context) string {
+	return func(ctx context.Context) string {
+		if uid := ctx.Value(GinContextJWTUIDKey); uid != nil {
+			return cast.ToString(uid)
+		}
+		c, ok := ctx.(ItfGinContext)
+		if !ok || t.Verify(c) != nil {
+			return ""
+		}
+		return cast.ToString(c.Value(GinContextJWTUIDKey))
+	}
+}
+
 // Logout ...
 func (t *Token) Logout(ctx context.Context, id int64) (err error) { return t.removeTokenData(ctx, id) }
 
@@ -2,8 +2,10 @@ package g2gin
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	_ "net/http/pprof" //pprof
 	"strings"
@@ -13,6 +15,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cast"
 
+	"github.com/atcharles/gof/v2/g2db"
 	"github.com/atcharles/gof/v2/g2util"
 	"github.com/atcharles/gof/v2/j2rpc"
 )
@@ -47,14 +50,21 @@ func (g *G2gin) Run() {
 		g1 = g1.Group(pathPrefix + apiRoot)
 	}
 	g1.Use(g.copyRequestBody())
+	g1.Use(midReadYourWrites)
 	g.useCors(g1)
 	g.useJ2rpc(g1)
+	_seconds := func(key string, def int) time.Duration {
+		if n := cast.ToInt(v[key]); n > 0 {
+			def = n
+		}
+		return time.Second * time.Duration(def)
+	}
 	srv := &http.Server{
 		Addr:              cast.ToString(v["port"]),
 		Handler:           eg,
-		ReadTimeout:       time.Second * 10,
+		ReadTimeout:       _seconds("read_timeout_seconds", 10),
 		ReadHeaderTimeout: time.Second * 10,
-		WriteTimeout:      time.Second * 10,
+		WriteTimeout:      _seconds("write_timeout_seconds", 10),
 		IdleTimeout:       time.Second * 30,
 	}
 	g.Graceful.RegHTTPServer(srv)
@@ -149,10 +159,10 @@ func (g *G2gin) useCors(rg *gin.RouterGroup) {
 			"Origin", "Content-Length", "Content-Type",
 			"Accept-Encoding", "Authorization", "X-Request-ID",
 			"X-Token", "X-Server", "X-Requested-With",
-			"Token",
+			"Token", "X-Api-Version", j2rpc.IdempotencyKeyHeader,
 		},
 		AllowCredentials:       true,
-		ExposeHeaders:          []string{"X-Token", "X-Server"},
+		ExposeHeaders:          []string{"X-Token", "X-Server", "Deprecation", "Sunset"},
 		MaxAge:                 12 * time.Hour,
 		AllowWildcard:          true,
 		AllowBrowserExtensions: true,
@@ -162,19 +172,82 @@ func (g *G2gin) useCors(rg *gin.RouterGroup) {
 	rg.Use(cors.New(_config))
 }
 
-// useJ2rpc ...
-func (g *G2gin) useJ2rpc(rg *gin.RouterGroup) {
+// SetJ2Option ...设置 j2rpc 的选项, 默认为 j2rpc.SnakeOption
+func (g *G2gin) SetJ2Option(opt *j2rpc.Option) { g.j2opt = opt }
+
+// NewJ2rpc ...按应用的选项与配置创建 j2rpc 服务并注册 j2Service, 不注册路由
+func (g *G2gin) NewJ2rpc(j2Service interface{}) j2rpc.RPCServer {
 	jsv := j2rpc.New(g.j2opt)
 	jsv.Logger().SetLevel(g2util.ParseLevel(g.Config.Viper().GetString("global.log_level")))
 	jsv.Logger().SetOutput(gin.DefaultWriter)
-	jsv.Opt().AddBeforeMiddleware(func(c *gin.Context, method string) { c.Set("method", method) }, []string{"^.*$"})
-	if g.j2Service != nil {
-		jsv.RegisterForApp(g.j2Service)
-		if ginRouter, ok := g.j2Service.(ItfGinRouter); ok {
-			ginRouter.Router(rg)
+	//方法默认超时时间, 应小于 write_timeout_seconds
+	if n := g.Config.Viper().GetInt("http_server.rpc_timeout_seconds"); n > 0 {
+		jsv.Opt().Timeout = time.Second * time.Duration(n)
+	}
+	//请求体的最大长度, 默认 5MB
+	if n := g.Config.Viper().GetInt64("http_server.rpc_max_request_mb"); n > 0 {
+		jsv.Opt().MaxRequestContentLength = n << 20
+	}
+	//生产模式中没有转换的错误只返回通用的信息, 原始错误记录在日志中
+	jsv.Opt().Production = g.Config.Viper().GetBool("http_server.rpc_production")
+	//审计日志, 写入 logs/rpc_audit.log
+	if g.Config.Viper().GetBool("http_server.rpc_audit") {
+		jsv.Opt().Audit = g.AbFile.MustLogIO("rpc_audit")
+		jsv.Opt().AddAuditRedact([]string{"password", "pwd", "token", "secret"})
+		//令牌校验通过后的用户id, 可以在 ItfGinRouter.J2rpc 中替换为 token.J2rpcUser()
+		jsv.Opt().AuditUser = func(ctx context.Context) string {
+			return cast.ToString(ctx.Value(g2db.GinContextJWTUIDKey))
+		}
+	}
+	jsv.Opt().AddBeforeMiddleware(func(c *gin.Context, method string) {
+		//ServeConn 的连接中没有 *gin.Context
+		if c != nil {
+			c.Set("method", method)
+		}
+	}, []string{"^.*$"})
+	if j2Service != nil {
+		jsv.RegisterForApp(j2Service)
+		if ginRouter, ok := j2Service.(ItfGinRouter); ok {
 			ginRouter.J2rpc(jsv)
 		}
 	}
+	return jsv
+}
+
+// useJ2rpc ...
+func (g *G2gin) useJ2rpc(rg *gin.RouterGroup) {
+	jsv := g.NewJ2rpc(g.j2Service)
+	if ginRouter, ok := g.j2Service.(ItfGinRouter); ok {
+		ginRouter.Router(rg)
+	}
 	rg.Use(midAddRequestID)
 	rg.Any("/jsonrpc", func(c *gin.Context) { jsv.Handler(c, c.Writer, c.Request) })
+	rg.GET("/jsonrpc/discover", func(c *gin.Context) { jsv.ServeDiscover(c.Writer, c.Request) })
+	rg.GET("/jsonrpc/ws", func(c *gin.Context) { jsv.ServeWebsocket(c, c.Writer, c.Request) })
+	//Prometheus 文本格式的方法指标, 如 /metrics
+	if path := g.Config.Viper().GetString("http_server.metrics_path"); len(path) > 0 {
+		rg.GET(path, func(c *gin.Context) { jsv.ServeMetrics(c.Writer, c.Request) })
+	}
+	//tcp, unix socket 上以换行符分隔的 JSON-RPC, 如 tcp://127.0.0.1:9001, unix:///tmp/app.sock
+	if addr := g.Config.Viper().GetString("http_server.rpc_listen"); len(addr) > 0 {
+		g.listenJ2rpc(jsv, addr)
+	}
+}
+
+// listenJ2rpc ...随 Graceful 优雅关闭
+func (g *G2gin) listenJ2rpc(jsv j2rpc.RPCServer, addr string) {
+	network, address, ok := strings.Cut(addr, "://")
+	if !ok {
+		network, address = "tcp", addr
+	}
+	ln, err := jsv.Listen(network, address)
+	if err != nil {
+		log.Fatalf("j2rpc listen on %s error:%s\n", addr, err.Error())
+	}
+	g.Graceful.RegProcessor(ln)
+	go func() {
+		if err := ln.Serve(); err != nil {
+			log.Fatalf("j2rpc listening on %s close with error:%s\n", addr, err.Error())
+		}
+	}()
 }
@@ -0,0 +1,49 @@
+package g2gin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/atcharles/gof/v2/g2util"
+	"github.com/atcharles/gof/v2/j2rpc"
+)
+
+type echo struct{}
+
+func (*echo) Echo(s string) string { return s }
+
+type echoService struct {
+	Echo *echo `j2rpc:""`
+}
+
+// TestNewJ2rpcMaxRequest ...http_server.rpc_max_request_mb 限制请求体的长度
+func TestNewJ2rpcMaxRequest(t *testing.T) {
+	cfg := new(g2util.Config)
+	cfg.Constructor()
+	cfg.Viper().Set("http_server.rpc_max_request_mb", 1)
+	g := &G2gin{Config: cfg}
+	g.SetJ2Option(&j2rpc.Option{SnakeNamespace: true, DisableMetrics: true})
+	jsv := g.NewJ2rpc(&echoService{Echo: new(echo)})
+	jsv.Logger().SetOutput(new(bytes.Buffer))
+	if n := jsv.Opt().MaxRequestContentLength; n != 1<<20 {
+		t.Fatalf("MaxRequestContentLength = %d, want 1MB", n)
+	}
+
+	post := func(param string) *httptest.ResponseRecorder {
+		body := `{"id":1,"method":"echo.echo","params":["` + param + `"]}`
+		r := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		jsv.ServeHTTP(w, r)
+		return w
+	}
+	if w := post(strings.Repeat("x", 1<<20)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("over 1MB: status %d", w.Code)
+	}
+	if w := post("x"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"result":"x"`) {
+		t.Errorf("under 1MB: status %d body %s", w.Code, w.Body.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/didip/tollbooth/v6"
 	"github.com/gin-gonic/gin"
 
+	"github.com/atcharles/gof/v2/g2db"
 	"github.com/atcharles/gof/v2/g2util"
 )
 
@@ -32,3 +33,9 @@ var midAddRequestID gin.HandlerFunc = func(c *gin.Context) {
 	}
 	c.Next()
 }
+
+// midReadYourWrites ...请求中写入数据之后, 同一个请求的读取使用主库(配置了 mysql.replicas 时)
+var midReadYourWrites gin.HandlerFunc = func(c *gin.Context) {
+	c.Set(g2db.ContextReadYourWritesKey, g2db.NewReadYourWrites())
+	c.Next()
+}
@@ -42,7 +42,8 @@ var jsonTimeParseLayouts = []string{
 	time.ANSIC,
 }
 
-// JSONTime ...
+// JSONTime ...数据表的字段由 xorm 按 time.Time 读写, 与数据库的时区(DatabaseTZ)转换;
+// 作为 SQL 的参数时由 ToDB 转换为 RFC3339 字符串
 type JSONTime time.Time
 
 // Add ...
@@ -67,18 +68,6 @@ func (p *JSONTime) Date() *JSONTime {
 	return &t
 }
 
-// FromDB ...
-func (p *JSONTime) FromDB(data []byte) error {
-	if len(data) == 0 {
-		return nil
-	}
-	if p == nil {
-		return nil
-	}
-	*p = *parseInlocation2jsonTime(string(data))
-	return nil
-}
-
 // GobDecode implements the gob.GobDecoder interface.
 func (p *JSONTime) GobDecode(data []byte) error {
 	if p == nil {
@@ -115,15 +104,20 @@ func (p *JSONTime) MarshalJSON() ([]byte, error) {
 	return data, nil
 }
 
-// Scan the value of time.Time
+// Scan the value of time.Time, 或没有 parseTime 时 MySQL 驱动返回的字符串
 func (p *JSONTime) Scan(v interface{}) error {
-	if v == nil {
+	switch value := v.(type) {
+	case nil:
 		return nil
-	}
-	value, ok := v.(time.Time)
-	if ok {
+	case time.Time:
 		*p = JSONTime(value)
 		return nil
+	case []byte:
+		*p = *parseInlocation2jsonTime(string(value))
+		return nil
+	case string:
+		*p = *parseInlocation2jsonTime(value)
+		return nil
 	}
 	return fmt.Errorf("can not convert %v to timestamp", v)
 }
@@ -160,7 +154,7 @@ func (p *JSONTime) Time() time.Time {
 	return p.Convert2Time()
 }
 
-// ToDB ...
+// ToDB ...SQL 参数, 与数据表的字段无关(没有 FromDB, xorm 不作为 Conversion 处理字段)
 func (p *JSONTime) ToDB() (b []byte, err error) {
 	if p == nil {
 		return nil, nil
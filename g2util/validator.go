@@ -14,6 +14,13 @@ import (
 // Valid ...
 var Valid = new(Validator).New()
 
+const (
+	//PatternMobile ...mobile 规则的手机号码
+	PatternMobile = `^1[3456789]\d{9}$`
+	//PatternUsername ...username 规则的用户账号
+	PatternUsername = `^\w{5,15}$`
+)
+
 // Validator ...
 type Validator struct {
 	valid *validator.Validate
@@ -52,6 +59,21 @@ func (v *Validator) TranslateZh(es error) (err error) {
 	return
 }
 
+// TranslateFields ...将 ValidationErrors 转换为字段错误列表, 其他错误时 ok 为false
+func (v *Validator) TranslateFields(es error) (list []FieldError, ok bool) {
+	_err, ok := es.(validator.ValidationErrors)
+	if !ok {
+		return
+	}
+	list = make([]FieldError, 0, len(_err))
+	for _, fieldError := range _err {
+		//去掉顶层结构体名称
+		_, field, _ := strings.Cut(fieldError.StructNamespace(), ".")
+		list = append(list, FieldError{Field: field, Rule: fieldError.Tag(), Message: fieldError.Translate(v.trans)})
+	}
+	return
+}
+
 // Valid ...
 func (v *Validator) Valid() *validator.Validate { return v.valid }
 
@@ -116,7 +138,7 @@ func (v *Validator) regPassword() (err error) {
 // regTranslationMobile ...
 func (v *Validator) regTranslationMobile() (err error) {
 	return v.validatorRegValidation("mobile", "{0}手机号码错误", func(fl validator.FieldLevel) bool {
-		return regexp.MustCompile(`^1[3456789]\d{9}$`).MatchString(fl.Field().String())
+		return regexp.MustCompile(PatternMobile).MatchString(fl.Field().String())
 	})
 }
 
@@ -124,7 +146,7 @@ func (v *Validator) regTranslationMobile() (err error) {
 func (v *Validator) regTranslationUsername() (err error) {
 	return v.validatorRegValidation("username", "{0}(用户账号为数字或字母组合;并且长度为[5-15])",
 		func(fl validator.FieldLevel) bool {
-			return regexp.MustCompile(`^\w{5,15}$`).MatchString(fl.Field().String())
+			return regexp.MustCompile(PatternUsername).MatchString(fl.Field().String())
 		},
 	)
 }
@@ -143,4 +165,11 @@ func (v *Validator) validatorRegValidation(tagName, text string, fn validator.Fu
 
 type listError []string
 
+// FieldError ...单个字段的校验错误, Field 为结构体字段路径, 如 Items[0].Name
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
 func (l listError) Error() string { return strings.Join(l, ",") }
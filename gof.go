@@ -90,6 +90,7 @@ func (a *Application) RunDefault(val interface{}) {
 	g2util.InjectPopulate(val, a.Default())
 	db := a.Mysql
 	db.TableRegister(g2util.ObjectTagInstances(val, "migrate")...)
+	a.G2cmd.SetJ2Service(val)
 	startFunc := func() { a.Gin.SetJ2Service(val); a.Run() }
 	migrateFunc := func() { db.Migrate() }
 	a.RunWithCmd(startFunc, migrateFunc)
@@ -0,0 +1,107 @@
+package j2rpc
+
+import (
+	"context"
+	"strings"
+)
+
+type (
+	//AccessRule ...方法的访问规则, Roles 不为空时必须登录且拥有其中之一的角色
+	AccessRule struct {
+		Auth  bool     `json:"auth"`
+		Roles []string `json:"roles,omitempty"`
+	}
+	//ItfAccessRules ...声明方法的访问规则, key为方法名, 优先于 j2rpc 标签中声明的命名空间规则
+	/**
+	func (u *User) J2rpcAccessRules() map[string]j2rpc.AccessRule {
+		return map[string]j2rpc.AccessRule{
+			"Login":      {},
+			"DeleteUser": {Auth: true, Roles: []string{"admin"}},
+		}
+	}
+
+	命名空间的默认规则, 在 app 结构体的字段上声明:
+	type app struct {
+		User  *User  `inject:"" j2rpc:"auth"`
+		Admin *Admin `inject:"" j2rpc:"auth,roles=admin ops"`
+	}
+	*/
+	ItfAccessRules interface{ J2rpcAccessRules() map[string]AccessRule }
+
+	//Authenticator ...校验请求的身份, 返回用户拥有的角色; ctx 为请求的原始 context(如 *gin.Context)
+	Authenticator func(ctx context.Context, method string) (roles []string, err error)
+)
+
+// requireAuth ...
+func (a *AccessRule) requireAuth() bool { return a != nil && (a.Auth || len(a.Roles) > 0) }
+
+// allow ...
+func (a *AccessRule) allow(roles []string) bool {
+	if len(a.Roles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		for _, want := range a.Roles {
+			if role == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String ...
+func (a *AccessRule) String() string {
+	if !a.requireAuth() {
+		return "public"
+	}
+	if len(a.Roles) == 0 {
+		return "auth"
+	}
+	return "auth, roles: " + strings.Join(a.Roles, " ")
+}
+
+// checkAccess ...未登录返回 ErrAuthorization, 没有权限返回 ErrForbidden
+func (s *server) checkAccess(base context.Context, method string, rule *AccessRule) error {
+	if !rule.requireAuth() {
+		return nil
+	}
+	if s.opt.Authenticator == nil {
+		s.logger.Errorf("[Access] %s requires auth, but Option.Authenticator is not set", method)
+		return NewError(ErrAuthorization, "unauthorized")
+	}
+	roles, err := s.opt.Authenticator(base, method)
+	if err != nil {
+		switch err.(type) {
+		case TokenError, ForbiddenError, *Error:
+			return err
+		default:
+			return NewError(ErrAuthorization, err.Error())
+		}
+	}
+	if !rule.allow(roles) {
+		return NewError(ErrForbidden, "permission denied")
+	}
+	return nil
+}
+
+// parseAccessTag ...解析 j2rpc 标签, 如 `j2rpc:"auth,roles=admin ops"`; 标签为空时返回nil
+func parseAccessTag(tag string) *AccessRule {
+	var rule *AccessRule
+	for _, opt := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "auth":
+			if rule == nil {
+				rule = new(AccessRule)
+			}
+			rule.Auth = true
+		case "roles":
+			if rule == nil {
+				rule = new(AccessRule)
+			}
+			rule.Auth, rule.Roles = true, strings.Fields(val)
+		}
+	}
+	return rule
+}
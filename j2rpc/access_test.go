@@ -0,0 +1,87 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type (
+	accessAPI struct{}
+
+	roleKey struct{}
+)
+
+func (*accessAPI) Login() string { return "login" }
+
+func (*accessAPI) Profile() string { return "profile" }
+
+func (*accessAPI) Remove() string { return "removed" }
+
+func (*accessAPI) J2rpcAccessRules() map[string]AccessRule {
+	return map[string]AccessRule{
+		"Profile": {Auth: true},
+		"Remove":  {Auth: true, Roles: []string{"admin"}},
+	}
+}
+
+// testAuthenticator ...ctx 中没有角色时未登录
+func testAuthenticator(ctx context.Context, _ string) ([]string, error) {
+	roles, ok := ctx.Value(roleKey{}).(string)
+	if !ok {
+		return nil, errors.New("no token")
+	}
+	return strings.Fields(roles), nil
+}
+
+func TestAccess(t *testing.T) {
+	s := newTestServer(t, &Option{SnakeNamespace: true, Authenticator: testAuthenticator}, new(accessAPI))
+	tests := []struct {
+		method string
+		login  bool
+		roles  string
+		code   ErrorCode
+	}{
+		{"test.login", false, "", 0},
+		{"test.profile", false, "", ErrAuthorization},
+		{"test.profile", true, "", 0},
+		{"test.remove", true, "user", ErrForbidden},
+		{"test.remove", true, "user admin", 0},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.login {
+			ctx = context.WithValue(ctx, roleKey{}, tt.roles)
+		}
+		w := handleRPC(s, ctx, `{"id":1,"method":"`+tt.method+`"}`)
+		if resp := decodeResponse(t, w); resp.errorCode() != tt.code {
+			t.Errorf("%s roles %q: error %+v, want code %d", tt.method, tt.roles, resp.Error, tt.code)
+		}
+	}
+
+	//没有设置 Authenticator 时需要登录的方法不可访问
+	s = newTestServer(t, nil, new(accessAPI))
+	if resp := decodeResponse(t, postRPC(s, `{"id":1,"method":"test.profile"}`)); resp.errorCode() != ErrAuthorization {
+		t.Errorf("without authenticator: %+v", resp.Error)
+	}
+}
+
+func TestParseAccessTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want *AccessRule
+	}{
+		{``, nil},
+		{`other`, nil},
+		{`auth`, &AccessRule{Auth: true}},
+		{`auth, roles=admin ops`, &AccessRule{Auth: true, Roles: []string{"admin", "ops"}}},
+		{`roles=admin`, &AccessRule{Auth: true, Roles: []string{"admin"}}},
+	}
+	for _, tt := range tests {
+		if got := parseAccessTag(tt.tag); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAccessTag(%q) = %+v, want %+v", tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+// auditRedacted ...脱敏字段的值
+const auditRedacted = "***"
+
+// auditRecord ...审计日志的一行
+type auditRecord struct {
+	Time      string          `json:"time"`
+	RequestID string          `json:"request_id,omitempty"`
+	Method    string          `json:"method"`
+	UID       string          `json:"uid,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	LatencyMS float64         `json:"latency_ms"`
+	Code      ErrorCode       `json:"code"`
+	Params    json.RawMessage `json:"params,omitempty"`
+}
+
+// AddAuditRedact ...审计日志中匹配的方法的参数中, 名称为 fields(不区分大小写)的字段记录为 ***;
+// 方法匹配规则与 AddBeforeMiddleware 相同, 没有指定方法时为所有方法, 多个匹配的规则合并
+/**
+opt.AddAuditRedact([]string{"password", "token"})
+opt.AddAuditRedact([]string{"id_card"}, []string{`^user\.`})
+*/
+func (o *Option) AddAuditRedact(fields []string, ls ...[]string) {
+	o.AuditRedacts = append(o.AuditRedacts, newMiddleInfo(fields, ls...))
+}
+
+// auditRedact ...method 需要脱敏的字段, key 为小写的字段名
+func (o *Option) auditRedact(method string) map[string]bool {
+	fields := make(map[string]bool)
+	for _, info := range o.AuditRedacts {
+		if list, ok := info.getMatchFunction(method).([]string); ok {
+			for _, f := range list {
+				fields[strings.ToLower(f)] = true
+			}
+		}
+	}
+	return fields
+}
+
+// audit ...每个请求(包括通知)写入一行 JSON 到 Option.Audit, 错误码为转换之后的错误码, 成功时为0
+func (s *server) audit(base, ctx context.Context, w http.ResponseWriter, r *http.Request, msg *RPCMessage,
+	elem []string, start time.Time, resp *RPCMessage) {
+	if s.opt.Audit == nil {
+		return
+	}
+	rec := &auditRecord{
+		Time:      start.Format(time.RFC3339Nano),
+		RequestID: w.Header().Get("request-id"),
+		Method:    msg.Method,
+		IP:        clientIP(base, r),
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if s.opt.AuditUser != nil {
+		rec.UID = s.opt.AuditUser(ctx)
+	}
+	if resp != nil && resp.Error != nil {
+		rec.Code = resp.Error.Code
+	}
+	var argNames []string
+	if len(elem) == 2 {
+		if cbk, err := s.getCallBack(elem); err == nil {
+			argNames = cbk.argNames
+		}
+	}
+	rec.Params = redactParams(msg.Codec(), msg.Params, argNames, s.opt.auditRedact(msg.Method))
+	bts, err := json.Marshal(rec)
+	if err != nil {
+		s.logger.Errorf("[Audit] %s", err.Error())
+		return
+	}
+	if _, err = s.opt.Audit.Write(append(bts, '\n')); err != nil {
+		s.logger.Errorf("[Audit] %s", err.Error())
+	}
+}
+
+// clientIP ...base 为 *gin.Context 时使用 gin 的 ClientIP(可信代理的 X-Forwarded-For), 否则为连接的地址
+func clientIP(base context.Context, r *http.Request) string {
+	if c, ok := base.(interface{ ClientIP() string }); ok {
+		return c.ClientIP()
+	}
+	if r == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// redactParams ...只记录 JSON 的参数; 按位置传递的参数使用 ItfParamNames 声明的名称匹配,
+// 没有名称的参数中只有对象按字段名称脱敏, 其他的值无法判断是否需要脱敏, 记录为 ***
+func redactParams(c Codec, params json.RawMessage, argNames []string, fields map[string]bool) json.RawMessage {
+	if c.ContentType() != contentType || len(params) == 0 {
+		return nil
+	}
+	if len(fields) == 0 {
+		return params
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	if list, ok := v.([]interface{}); ok {
+		for i, item := range list {
+			if i < len(argNames) {
+				if fields[strings.ToLower(argNames[i])] {
+					list[i] = auditRedacted
+				}
+				continue
+			}
+			if _, isObject := item.(map[string]interface{}); !isObject {
+				list[i] = auditRedacted
+			}
+		}
+	}
+	bts, err := json.Marshal(redactValue(v, fields))
+	if err != nil {
+		return nil
+	}
+	return bts
+}
+
+// redactValue ...递归替换对象中需要脱敏的字段
+func redactValue(v interface{}, fields map[string]bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if fields[strings.ToLower(k)] {
+				val[k] = auditRedacted
+				continue
+			}
+			val[k] = redactValue(item, fields)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item, fields)
+		}
+	}
+	return v
+}
@@ -0,0 +1,76 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+type auditAPI struct{}
+
+func (*auditAPI) Login(name, password string) bool { return true }
+
+func (*auditAPI) Update(id int, args map[string]string) bool { return true }
+
+func (*auditAPI) J2rpcParamNames() map[string][]string {
+	return map[string][]string{"Login": {"name", "password"}}
+}
+
+func TestAudit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	opt := &Option{SnakeNamespace: true, Audit: buf}
+	opt.AuditUser = func(ctx context.Context) string { s, _ := ctx.Value(userKey{}).(string); return s }
+	opt.AddAuditRedact([]string{"password", "token"})
+	s := newTestServer(t, opt, new(auditAPI))
+	handleRPC(s, context.WithValue(context.Background(), userKey{}, "u1"),
+		`{"id":1,"method":"test.login","params":["a","secret"]}`)
+	postRPC(s, `{"id":1,"method":"test.login","params":{"name":"a","password":"secret"}}`)
+	postRPC(s, `{"id":1,"method":"test.update","params":[1,{"token":"t","x":"y"}]}`)
+	postRPC(s, `{"method":"test.none","params":["p"]}`)
+
+	want := []struct {
+		uid    string
+		code   ErrorCode
+		params string
+	}{
+		{"u1", 0, `["a","***"]`},
+		{"", 0, `{"name":"a","password":"***"}`},
+		{"", 0, `["***",{"token":"***","x":"y"}]`},
+		{"", ErrNoMethod, `["***"]`},
+	}
+	dec := json.NewDecoder(buf)
+	for i, w := range want {
+		rec := new(auditRecord)
+		if err := dec.Decode(rec); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if rec.UID != w.uid || rec.Code != w.code || string(rec.Params) != w.params || len(rec.Method) == 0 {
+			t.Errorf("record %d = %+v, params %s, want %+v", i, rec, rec.Params, w)
+		}
+	}
+}
+
+func TestRedactParams(t *testing.T) {
+	fields := map[string]bool{"password": true}
+	tests := []struct {
+		params string
+		names  []string
+		fields map[string]bool
+		want   string
+	}{
+		{`["a","b"]`, nil, nil, `["a","b"]`},
+		{`["a","b"]`, []string{"name", "password"}, fields, `["a","***"]`},
+		{`["a",[1],{"Password":"b","n":1.50}]`, nil, fields, `["***","***",{"Password":"***","n":1.50}]`},
+		{`{"user":{"password":"b"}}`, nil, fields, `{"user":{"password":"***"}}`},
+	}
+	for _, tt := range tests {
+		if got := redactParams(JSONCodec, json.RawMessage(tt.params), tt.names, tt.fields); string(got) != tt.want {
+			t.Errorf("redactParams(%s, %v) = %s, want %s", tt.params, tt.names, got, tt.want)
+		}
+	}
+	if got := redactParams(MsgpackCodec, json.RawMessage{0x90}, nil, fields); got != nil {
+		t.Errorf("msgpack params = %v, want nil", got)
+	}
+}
@@ -0,0 +1,113 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+// batchResponseWriter ...批量请求的元素并发执行, 每个元素使用独立的响应头, 全部执行之后合并到 w
+type batchResponseWriter struct {
+	http.ResponseWriter
+	mu     *sync.Mutex
+	header http.Header
+}
+
+func (w *batchResponseWriter) Header() http.Header { return w.header }
+
+func (w *batchResponseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *batchResponseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// newBatchResponseWriters ...
+func newBatchResponseWriters(w http.ResponseWriter, n int) []*batchResponseWriter {
+	mu, h := new(sync.Mutex), w.Header()
+	list := make([]*batchResponseWriter, n)
+	for i := range list {
+		list[i] = &batchResponseWriter{ResponseWriter: w, mu: mu, header: h.Clone()}
+	}
+	return list
+}
+
+// mergeBatchHeaders ...按请求顺序合并元素修改过的响应头
+func mergeBatchHeaders(w http.ResponseWriter, list []*batchResponseWriter) {
+	h := w.Header()
+	origin := h.Clone()
+	for _, bw := range list {
+		for k, vv := range bw.header {
+			if ov, ok := origin[k]; !ok || !slices.Equal(ov, vv) {
+				h[k] = vv
+			}
+		}
+	}
+}
+
+// processBatch ...处理批量请求, 每个元素都经过中间件与回调, 按请求顺序返回响应数组
+func (s *server) processBatch(c Codec, base, ctx context.Context, w http.ResponseWriter, r *http.Request,
+	body []byte) interface{} {
+	var raws []json.RawMessage
+	if err := c.Unmarshal(body, &raws); err != nil {
+		return errorResponse(c, NewError(ErrParse, err.Error()))
+	}
+	if len(raws) == 0 {
+		return errorResponse(c, NewError(ErrInvalidRequest, "empty batch"))
+	}
+
+	answers := make([]*RPCMessage, len(raws))
+	writers := newBatchResponseWriters(w, len(raws))
+	s.opt.batchEach(len(raws), func(i int) { answers[i] = s.handleBatchElem(c, base, ctx, writers[i], r, raws[i]) })
+	mergeBatchHeaders(w, writers)
+
+	responses := make([]*RPCMessage, 0, len(answers))
+	for _, answer := range answers {
+		if answer != nil {
+			responses = append(responses, answer)
+		}
+	}
+	//全部为通知时,不返回任何内容
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+// handleBatchElem ...returns nil for notifications; 元素可能并发执行, panic 时返回该元素的 ErrInternal 响应
+func (s *server) handleBatchElem(c Codec, base, ctx context.Context, w http.ResponseWriter, r *http.Request,
+	raw json.RawMessage) (answer *RPCMessage) {
+	msg := new(RPCMessage)
+	defer func() {
+		if p := recover(); p != nil {
+			err := s.stack(p, msg.Method)
+			answer = nil
+			if !msg.isNotification() {
+				answer = NewResponse(msg, nil, err).output()
+			}
+		}
+	}()
+	if err := c.Unmarshal(raw, msg); err != nil {
+		return errorResponse(c, NewError(ErrInvalidRequest, err.Error()))
+	}
+	msg.codec = c
+	if !msg.isNotification() && !msg.hasValidID() {
+		msg.setError(NewError(ErrInvalidRequest, "id is invalid"))
+		msg.ID = nil
+		return msg.output()
+	}
+
+	if msg.isNotification() {
+		s.handleNotification(base, ctx, w, r, msg)
+		return nil
+	}
+	return s.handle(base, ctx, w, r, msg).encodeResult().output()
+}
@@ -0,0 +1,102 @@
+package j2rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	for _, concurrency := range []int{0, 4} {
+		api := new(testAPI)
+		s := newTestServer(t, &Option{SnakeNamespace: true, BatchConcurrency: concurrency}, api)
+		w := postRPC(s, `[
+			{"id":1,"method":"test.add","params":[1,2]},
+			{"method":"test.notify"},
+			{"id":"b","method":"test.none"},
+			{"id":{},"method":"test.echo"},
+			1,
+			{"id":3,"method":"test.echo","params":["x"]}
+		]`)
+		list := decodeBatch(t, w)
+		want := []struct {
+			id     string
+			result string
+			code   ErrorCode
+		}{
+			{`1`, `3`, 0},
+			{`"b"`, ``, ErrNoMethod},
+			{`null`, ``, ErrInvalidRequest},
+			{`null`, ``, ErrInvalidRequest},
+			{`3`, `"x"`, 0},
+		}
+		if len(list) != len(want) {
+			t.Fatalf("concurrency %d: got %d responses, want %d: %s", concurrency, len(list), len(want), w.Body)
+		}
+		for i, r := range list {
+			if string(r.ID) != want[i].id || string(r.Result) != want[i].result || r.errorCode() != want[i].code {
+				t.Errorf("concurrency %d: response %d = id %s result %s error %+v", concurrency, i, r.ID,
+					r.Result, r.Error)
+			}
+		}
+		if api.notified != 1 {
+			t.Errorf("concurrency %d: notified %d times, want 1", concurrency, api.notified)
+		}
+	}
+}
+
+func TestBatchInvalid(t *testing.T) {
+	s := newTestServer(t, nil)
+	if resp := decodeResponse(t, postRPC(s, `[]`)); resp.errorCode() != ErrInvalidRequest {
+		t.Errorf("empty batch: %+v", resp.Error)
+	}
+	if resp := decodeResponse(t, postRPC(s, `[{"id":1,`)); resp.errorCode() != ErrParse {
+		t.Errorf("broken batch: %+v", resp.Error)
+	}
+	w := postRPC(s, `[{"method":"test.notify"},{"method":"test.notify"}]`)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("notification batch: status %d body %q", w.Code, w.Body)
+	}
+}
+
+// TestBatchConcurrentHeaders ...并发执行的元素写入响应头, 使用 -race 检查
+func TestBatchConcurrentHeaders(t *testing.T) {
+	s := newTestServer(t, &Option{SnakeNamespace: true, BatchConcurrency: 8})
+	elems := make([]string, 0, 32)
+	for i := 0; i < 32; i++ {
+		elems = append(elems, fmt.Sprintf(`{"id":%d,"method":"test.old"}`, i))
+	}
+	w := postRPC(s, "["+strings.Join(elems, ",")+"]", "Accept-Encoding", "gzip")
+	if got := w.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("Deprecation header = %q", got)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+}
+
+// TestBatchConcurrentPanic ...并发执行的元素 panic 时返回该元素的错误, 不影响其他元素
+func TestBatchConcurrentPanic(t *testing.T) {
+	opt := &Option{SnakeNamespace: true, BatchConcurrency: 4}
+	opt.AddMiddleware(func(ctx context.Context, req *RPCMessage, next Handler) *RPCMessage {
+		panic("mw")
+	}, []string{"test.old"})
+	s := newTestServer(t, opt)
+	list := decodeBatch(t, postRPC(s, `[
+		{"id":1,"method":"test.echo","params":["a"]},
+		{"id":"p","method":"test.old"},
+		{"method":"test.old"},
+		{"id":3,"method":"test.add","params":[1,2]}
+	]`))
+	if len(list) != 3 {
+		t.Fatalf("got %d responses, want 3", len(list))
+	}
+	if string(list[0].Result) != `"a"` || string(list[2].Result) != `3` {
+		t.Errorf("other elements: %s %s", list[0].Result, list[2].Result)
+	}
+	if string(list[1].ID) != `"p"` || list[1].errorCode() != ErrInternal {
+		t.Errorf("panic element: id %s error %+v", list[1].ID, list[1].Error)
+	}
+}
@@ -0,0 +1,161 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atcharles/gof/v2/g2cache/store"
+	"github.com/atcharles/gof/v2/json"
+)
+
+type (
+	//ItfCacheable ...声明可以缓存结果的只读方法, key 为方法名, "*" 为整个命名空间; 需要设置 Option.Cache
+	/**
+	func (u *User) J2rpcCache() map[string]j2rpc.CacheRule {
+		return map[string]j2rpc.CacheRule{
+			"Get":     {TTL: time.Minute},
+			"Profile": {TTL: time.Minute, PerUser: true},
+		}
+	}
+
+	写入数据后清除缓存: jsv.InvalidateCache("user.get"), 多个实例时使用 mysql.Redis.PubJ2rpcCacheInvalidate("user")
+	*/
+	ItfCacheable interface{ J2rpcCache() map[string]CacheRule }
+
+	//CacheRule ...方法结果的缓存规则
+	CacheRule struct {
+		//缓存时间, 小于等于0时不缓存
+		TTL time.Duration
+		//按用户区分缓存, 用户由 Option.CacheUser 获取; 没有用户时不使用缓存
+		PerUser bool
+		//缓存的key, 为nil时为规范化的参数(对象按字段名排序); 返回空字符串时不使用缓存
+		Key func(ctx context.Context, params json.RawMessage) string
+	}
+)
+
+// cacheGeneration ...命名空间与方法的缓存版本, 清除缓存时增加版本, 旧版本的缓存不再被读取
+func (s *server) cacheGeneration(namespace, method string) string {
+	s.cacheMu.RLock()
+	defer s.cacheMu.RUnlock()
+	return strconv.FormatInt(s.cacheEpoch, 36) + "." + strconv.FormatUint(s.cacheGens[namespace], 36) + "." +
+		strconv.FormatUint(s.cacheGens[method], 36)
+}
+
+// InvalidateCache ...清除方法(user.get)或命名空间(user, v2.user)的结果缓存, 为空时清除全部
+func (s *server) InvalidateCache(names ...string) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if len(names) == 0 {
+		//进程重启后 cacheEpoch 不同, 持久化的缓存也不会被读取
+		s.cacheEpoch = time.Now().UnixNano()
+		s.cacheGens = make(map[string]uint64)
+		return
+	}
+	for _, name := range names {
+		elem := strings.Split(name, splitMethodSeparator)
+		for i, e := range elem {
+			elem[i] = s.formatName(e)
+		}
+		s.cacheGens[strings.Join(elem, splitMethodSeparator)]++
+	}
+}
+
+// cacheKey ...返回空字符串时不使用缓存
+func (s *server) cacheKey(ctx context.Context, msg *RPCMessage, elem []string, rule *CacheRule) string {
+	var user string
+	if rule.PerUser {
+		if s.opt.CacheUser != nil {
+			user = s.opt.CacheUser(ctx)
+		}
+		if len(user) == 0 {
+			return ""
+		}
+	}
+	var key string
+	if rule.Key != nil {
+		if key = rule.Key(ctx, msg.Params); len(key) == 0 {
+			return ""
+		}
+	} else {
+		sum := sha256.Sum256(canonicalParams(msg.Codec(), msg.Params))
+		key = hex.EncodeToString(sum[:])
+	}
+	return "j2rpc:cache:" + s.cacheGeneration(elem[0], msg.Method) + ":" + msg.Codec().ContentType() + ":" +
+		msg.Method + ":" + user + ":" + key
+}
+
+// cached ...读取缓存的结果, 过期或不存在时返回nil
+func (s *server) cached(key string) json.RawMessage {
+	data, err := s.opt.Cache.Get(key)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			s.logger.Warnf("[Cache] get %s: %s", key, err.Error())
+		}
+		return nil
+	}
+	if len(data) < 8 || time.Now().UnixNano() >= int64(binary.BigEndian.Uint64(data)) {
+		_ = s.opt.Cache.Delete(key)
+		return nil
+	}
+	return data[8:]
+}
+
+// storeCache ...使用请求的编码编码结果, 过期时间保存在值的前8个字节
+func (s *server) storeCache(key string, c Codec, res interface{}, ttl time.Duration) {
+	bts, err := c.Marshal(res)
+	if err != nil {
+		return
+	}
+	buf := make([]byte, 8+len(bts))
+	binary.BigEndian.PutUint64(buf, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(buf[8:], bts)
+	if err = s.opt.Cache.Set(key, buf); err != nil {
+		s.logger.Warnf("[Cache] set %s: %s", key, err.Error())
+	}
+}
+
+// callCached ...cbk 声明了缓存规则时, 优先返回缓存的结果
+func (s *server) callCached(ctx context.Context, msg *RPCMessage, elem []string, cbk callback,
+	call func() (interface{}, error)) (interface{}, error) {
+	rule := cbk.cache
+	if s.opt.Cache == nil || rule == nil || rule.TTL <= 0 || cbk.isSub {
+		return call()
+	}
+	key := s.cacheKey(ctx, msg, elem, rule)
+	if len(key) == 0 {
+		return call()
+	}
+	if data := s.cached(key); data != nil {
+		return data, nil
+	}
+	res, err := call()
+	if err == nil {
+		s.storeCache(key, msg.Codec(), res, rule.TTL)
+	}
+	return res, err
+}
+
+// canonicalParams ...JSON 的参数重新编码, 对象的字段按名称排序; 其他编码使用原始内容
+func canonicalParams(c Codec, params json.RawMessage) []byte {
+	if c.ContentType() != contentType || len(params) == 0 {
+		return params
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return params
+	}
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return params
+	}
+	return bts
+}
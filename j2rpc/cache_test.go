@@ -0,0 +1,122 @@
+package j2rpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atcharles/gof/v2/g2cache/store"
+)
+
+type (
+	//memCache ...store.ItfCache
+	memCache struct {
+		mu   sync.Mutex
+		data map[string][]byte
+	}
+
+	userKey struct{}
+
+	cacheAPI struct{ calls int32 }
+)
+
+func newMemCache() *memCache { return &memCache{data: make(map[string][]byte)} }
+
+func (m *memCache) String() string { return "mem" }
+
+func (m *memCache) Set(key string, data []byte) error {
+	m.mu.Lock()
+	m.data[key] = data
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memCache) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.data[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return data, nil
+}
+
+func (m *memCache) Delete(key string) error {
+	m.mu.Lock()
+	delete(m.data, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memCache) Reset() error {
+	m.mu.Lock()
+	m.data = make(map[string][]byte)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memCache) CacheInstance() store.ItfCache { return m }
+
+// Get ...返回第几次执行
+func (c *cacheAPI) Get(id int) map[string]int {
+	return map[string]int{"id": id, "call": int(atomic.AddInt32(&c.calls, 1))}
+}
+
+func (c *cacheAPI) Short(id int) int { return int(atomic.AddInt32(&c.calls, 1)) }
+
+func (c *cacheAPI) Mine() int { return int(atomic.AddInt32(&c.calls, 1)) }
+
+func (c *cacheAPI) J2rpcCache() map[string]CacheRule {
+	return map[string]CacheRule{
+		"Get":   {TTL: time.Minute},
+		"Short": {TTL: 20 * time.Millisecond},
+		"Mine":  {TTL: time.Minute, PerUser: true},
+	}
+}
+
+func TestCache(t *testing.T) {
+	api := new(cacheAPI)
+	opt := &Option{SnakeNamespace: true, Cache: newMemCache()}
+	opt.CacheUser = func(ctx context.Context) string { s, _ := ctx.Value(userKey{}).(string); return s }
+	s := newTestServer(t, opt, api)
+	result := func(body string) string { return string(decodeResponse(t, postRPC(s, body)).Result) }
+
+	first := result(`{"id":1,"method":"test.get","params":[1]}`)
+	if first != `{"call":1,"id":1}` {
+		t.Fatalf("first call = %s", first)
+	}
+	if got := result(`{"id":2,"method":"test.get","params":[1]}`); got != first {
+		t.Errorf("cached call = %s, want %s", got, first)
+	}
+	if got := result(`{"id":3,"method":"test.get","params":[2]}`); got != `{"call":2,"id":2}` {
+		t.Errorf("other params = %s", got)
+	}
+	s.InvalidateCache("test.get")
+	if got := result(`{"id":4,"method":"test.get","params":[1]}`); got != `{"call":3,"id":1}` {
+		t.Errorf("after invalidate = %s", got)
+	}
+
+	const short = `{"id":1,"method":"test.short","params":[1]}`
+	if a, b := result(short), result(short); a != b {
+		t.Errorf("short: %s != %s", a, b)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := result(short); got != `5` {
+		t.Errorf("expired = %s, want 5", got)
+	}
+
+	//没有用户时不使用缓存
+	if a, b := result(`{"id":1,"method":"test.mine"}`), result(`{"id":1,"method":"test.mine"}`); a == b {
+		t.Errorf("per-user without user: %s == %s", a, b)
+	}
+	call := func(user string) string {
+		w := handleRPC(s, context.WithValue(context.Background(), userKey{}, user), `{"id":1,"method":"test.mine"}`)
+		return string(decodeResponse(t, w).Result)
+	}
+	u1 := call("u1")
+	if call("u1") != u1 || call("u2") == u1 {
+		t.Errorf("per-user cache mixed users")
+	}
+}
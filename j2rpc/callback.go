@@ -2,6 +2,7 @@ package j2rpc
 
 import (
 	"context"
+	"net/http"
 	"reflect"
 )
 
@@ -14,10 +15,22 @@ type callback struct {
 	fn reflect.Value
 	//input argument types
 	argTypes []reflect.Type
+	//input argument names, used for by-name params
+	argNames []string
 	//method's first argument is a context (not included in argTypes)
 	hasCtx bool
 	//err return idx, of -1 when method cannot return error
 	errPos int
+	//method returns a channel or *Subscription, only available over websocket
+	isSub bool
+	//access rule, nil when the method is public
+	access *AccessRule
+	//indexes of struct arguments validated by g2util.Valid
+	validArgs []int
+	//deprecation declared by ItfDeprecated, nil when the method is not deprecated
+	deprecation *Deprecation
+	//result cache rule declared by ItfCacheable, nil when the result is not cached
+	cache *CacheRule
 }
 
 // call invokes the callback.
@@ -58,6 +71,41 @@ func (c *callback) call(ctx context.Context, args []reflect.Value) (res interfac
 	return rv.Interface(), err
 }
 
+// context ...中间件派生的 ctx 与方法声明的类型(如 *gin.Context)不一致时, 使用原始的 ctx
+func (c *callback) context(base, ctx context.Context) context.Context {
+	if !c.hasCtx || ctx == base {
+		return ctx
+	}
+	idx := 0
+	if c.rcv.IsValid() {
+		idx++
+	}
+	if reflect.TypeOf(ctx).AssignableTo(c.fn.Type().In(idx)) {
+		return ctx
+	}
+	return base
+}
+
+// detachedContext ...在新的 goroutine 中执行的方法使用的 ctx; 方法声明的类型(如 *gin.Context)与 ctx 不一致时,
+// 使用 base 的副本(Copy), 副本的 Request 携带 ctx 的截止时间; 与 gin 相同, 副本不能写入响应
+func (c *callback) detachedContext(base, ctx context.Context) context.Context {
+	if cc := c.context(base, ctx); cc != base || base == ctx {
+		return cc
+	}
+	m := reflect.ValueOf(base).MethodByName("Copy")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 ||
+		m.Type().Out(0) != reflect.TypeOf(base) {
+		return base
+	}
+	cp := m.Call(nil)[0]
+	if req := reflect.Indirect(cp).FieldByName("Request"); req.IsValid() && req.CanSet() {
+		if r, ok := req.Interface().(*http.Request); ok && r != nil {
+			req.Set(reflect.ValueOf(r.WithContext(ctx)))
+		}
+	}
+	return cp.Interface().(context.Context)
+}
+
 // makeArgTypes ...
 func (c *callback) makeArgTypes() bool {
 	fnt := c.fn.Type()
@@ -90,6 +138,9 @@ func (c *callback) makeArgTypes() bool {
 		c.hasCtx = true
 		firstArg++
 	}
+	if fnt.NumOut() > 0 && c.errPos != 0 {
+		c.isSub = isSubscriptionType(fnt.Out(0))
+	}
 	//Add all remaining parameters.
 	c.argTypes = make([]reflect.Type, fnt.NumIn()-firstArg)
 	for i := firstArg; i < fnt.NumIn(); i++ {
@@ -98,6 +149,18 @@ func (c *callback) makeArgTypes() bool {
 	return true
 }
 
+// resultType ...returns nil when the method has no result value; subscriptions answer with the id
+func (c *callback) resultType() reflect.Type {
+	if c.isSub {
+		return stringType
+	}
+	fnt := c.fn.Type()
+	if fnt.NumOut() == 0 || c.errPos == 0 {
+		return nil
+	}
+	return fnt.Out(0)
+}
+
 func value2err(val reflect.Value) error {
 	if !isErrorType(val.Type()) {
 		return nil
@@ -0,0 +1,255 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	//Client ...j2rpc 服务的客户端, 与 RPCMessage 的传输格式一致
+	/**
+	cl := j2rpc.NewClient("http://127.0.0.1:8080/jsonrpc").SetToken(token)
+	var val string
+	err := cl.Call(ctx, "api.get", &val, "key")
+	*/
+	Client struct {
+		url        string
+		httpClient *http.Client
+		codec      Codec
+
+		mu     sync.RWMutex
+		header http.Header
+		id     uint64
+	}
+	//BatchElem ...批量请求的元素
+	BatchElem struct {
+		Method string
+		Args   []interface{}
+		//Result 为空时不解析结果
+		Result interface{}
+		//Notify 为true时, 以通知的方式发送, 不返回结果
+		Notify bool
+		//Error 请求返回的错误, 可能为 TokenError, ForbiddenError 或 *Error
+		Error error
+	}
+)
+
+// NewClient ...
+func NewClient(url string, httpClient ...*http.Client) *Client {
+	c := &Client{url: url, httpClient: http.DefaultClient, codec: JSONCodec, header: make(http.Header)}
+	if len(httpClient) > 0 && httpClient[0] != nil {
+		c.httpClient = httpClient[0]
+	}
+	return c
+}
+
+// BatchCall ...在一次请求中发送多个调用, 每个元素的错误写入 BatchElem.Error
+func (c *Client) BatchCall(ctx context.Context, elems []*BatchElem) (err error) {
+	if len(elems) == 0 {
+		return
+	}
+	msgs := make([]*RPCMessage, len(elems))
+	byID := make(map[string]*BatchElem, len(elems))
+	for i, elem := range elems {
+		if msgs[i], err = c.newMessage(elem.Method, !elem.Notify, elem.Args...); err != nil {
+			return
+		}
+		if !elem.Notify {
+			byID[string(msgs[i].ID)] = elem
+		}
+	}
+	body, err := c.send(ctx, msgs)
+	if err != nil || len(byID) == 0 {
+		return
+	}
+
+	var answers []*RPCMessage
+	if !isBatch(c.codec, body) {
+		//服务器无法处理批量请求时, 返回单个错误响应
+		answer, e := c.decodeMessage(body)
+		if e != nil {
+			return e
+		}
+		return answer.clientError()
+	}
+	if err = c.codec.Unmarshal(body, &answers); err != nil {
+		return
+	}
+	for _, answer := range answers {
+		answer.codec = c.codec
+		elem, ok := byID[string(answer.ID)]
+		if !ok {
+			continue
+		}
+		delete(byID, string(answer.ID))
+		elem.Error = answer.decodeResult(elem.Result)
+	}
+	for id, elem := range byID {
+		elem.Error = fmt.Errorf("missing response for id %s", id)
+	}
+	return
+}
+
+// Call ...调用远程方法, result 为指针, 为nil时忽略结果
+func (c *Client) Call(ctx context.Context, method string, result interface{}, args ...interface{}) (err error) {
+	msg, err := c.newMessage(method, true, args...)
+	if err != nil {
+		return
+	}
+	body, err := c.send(ctx, msg)
+	if err != nil {
+		return
+	}
+	//服务器按通知处理时没有响应
+	if len(body) == 0 {
+		return fmt.Errorf("missing response for id %s", msg.ID)
+	}
+	answer, err := c.decodeMessage(body)
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(answer.ID, msg.ID) {
+		//无法解析请求的 id 时, 错误响应的 id 为 null
+		if err = answer.clientError(); err != nil {
+			return
+		}
+		return fmt.Errorf("response id %s does not match request id %s", answer.ID, msg.ID)
+	}
+	return answer.decodeResult(result)
+}
+
+// Notify ...发送通知, 服务器不返回结果
+func (c *Client) Notify(ctx context.Context, method string, args ...interface{}) (err error) {
+	msg, err := c.newMessage(method, false, args...)
+	if err != nil {
+		return
+	}
+	_, err = c.send(ctx, msg)
+	return
+}
+
+// SetCodec ...设置请求与响应的编码, 默认为 JSONCodec
+func (c *Client) SetCodec(codec Codec) *Client {
+	c.codec = codec
+	return c
+}
+
+// SetHeader ...设置每个请求都会携带的header
+func (c *Client) SetHeader(key, value string) *Client {
+	c.mu.Lock()
+	c.header.Set(key, value)
+	c.mu.Unlock()
+	return c
+}
+
+// SetToken ...设置身份令牌
+func (c *Client) SetToken(token string) *Client { return c.SetHeader("Token", token) }
+
+// decodeMessage ...
+func (c *Client) decodeMessage(body []byte) (msg *RPCMessage, err error) {
+	msg = new(RPCMessage)
+	err = c.codec.Unmarshal(body, msg)
+	msg.codec = c.codec
+	return
+}
+
+// newMessage ...
+func (c *Client) newMessage(method string, withID bool, args ...interface{}) (msg *RPCMessage, err error) {
+	msg = &RPCMessage{Version: vsn, Method: method, codec: c.codec}
+	if withID {
+		if msg.ID, err = c.codec.Marshal(atomic.AddUint64(&c.id, 1)); err != nil {
+			return
+		}
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	msg.Params, err = c.codec.Marshal(args)
+	return
+}
+
+// send ...
+func (c *Client) send(ctx context.Context, val interface{}) (body []byte, err error) {
+	bts, err := c.codec.Marshal(val)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(bts))
+	if err != nil {
+		return
+	}
+	c.mu.RLock()
+	for k, vs := range c.header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	c.mu.RUnlock()
+	req.Header.Set("Content-Type", c.codec.ContentType())
+	req.Header.Set("Accept", c.codec.ContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if body, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNoContent:
+		body = nil
+	case c.codec.Kind(body) == reflect.Map, c.codec.Kind(body) == reflect.Slice:
+		//中间件中断请求时, 可能仍然返回 json-rpc 格式的错误
+	default:
+		err = httpStatusError(resp.StatusCode, body)
+	}
+	return
+}
+
+// clientError ...将响应中的错误还原为对应的错误类型
+func (r *RPCMessage) clientError() error {
+	if r.Error == nil {
+		return nil
+	}
+	switch r.Error.Code {
+	case ErrAuthorization:
+		return TokenError(r.Error.Message)
+	case ErrForbidden:
+		return ForbiddenError(r.Error.Message)
+	default:
+		return r.Error
+	}
+}
+
+// decodeResult ...
+func (r *RPCMessage) decodeResult(result interface{}) error {
+	if err := r.clientError(); err != nil {
+		return err
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return r.Codec().Unmarshal(r.Result, result)
+}
+
+// httpStatusError ...
+func httpStatusError(code int, body []byte) error {
+	msg := string(bytes.TrimSpace(body))
+	if len(msg) == 0 {
+		msg = http.StatusText(code)
+	}
+	switch code {
+	case http.StatusUnauthorized:
+		return TokenError(msg)
+	case http.StatusForbidden:
+		return ForbiddenError(msg)
+	default:
+		return NewError(ErrorCode(code), msg)
+	}
+}
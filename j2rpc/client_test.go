@@ -0,0 +1,79 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	api := new(testAPI)
+	srv := httptest.NewServer(newTestServer(t, nil, api))
+	defer srv.Close()
+	cl := NewClient(srv.URL)
+	ctx := context.Background()
+
+	var sum int
+	if err := cl.Call(ctx, "test.add", &sum, 1, 2); err != nil || sum != 3 {
+		t.Errorf("add = %d, %v", sum, err)
+	}
+	var e *Error
+	if err := cl.Call(ctx, "test.none", nil); !errors.As(err, &e) || e.Code != ErrNoMethod {
+		t.Errorf("none: %v", err)
+	}
+	if err := cl.Notify(ctx, "test.notify"); err != nil {
+		t.Errorf("notify: %v", err)
+	}
+
+	var echo string
+	elems := []*BatchElem{
+		{Method: "test.echo", Args: []interface{}{"x"}, Result: &echo},
+		{Method: "test.notify", Notify: true},
+		{Method: "test.fail"},
+	}
+	if err := cl.BatchCall(ctx, elems); err != nil {
+		t.Fatal(err)
+	}
+	if echo != "x" || elems[0].Error != nil || elems[2].Error == nil {
+		t.Errorf("batch: echo=%q errors=%v,%v", echo, elems[0].Error, elems[2].Error)
+	}
+	if api.notified != 2 {
+		t.Errorf("notified %d times, want 2", api.notified)
+	}
+}
+
+func TestClientResponseID(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		err    string
+	}{
+		{http.StatusOK, `{"jsonrpc":"2.0","id":99,"result":1}`, "does not match"},
+		{http.StatusOK, `{"jsonrpc":"2.0","id":null,"result":1}`, "does not match"},
+		{http.StatusOK, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`, "parse error"},
+		{http.StatusNoContent, ``, "missing response"},
+		{http.StatusOK, ``, "missing response"},
+		{http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":1}`, ""},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(tt.status)
+			_, _ = w.Write([]byte(tt.body))
+		}))
+		var n int
+		err := NewClient(srv.URL).Call(context.Background(), "test.add", &n, 1, 0)
+		srv.Close()
+		if len(tt.err) == 0 {
+			if err != nil || n != 1 {
+				t.Errorf("%s: %d, %v", tt.body, n, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%d %s: error %v, want %q", tt.status, tt.body, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package j2rpc
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"reflect"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+var (
+	//JSONCodec ...默认的编码, 使用 gof/json (根据编译标签选择实现)
+	JSONCodec Codec = jsonCodec{}
+	//MsgpackCodec ...application/msgpack
+	MsgpackCodec Codec = newBinaryCodec("application/msgpack", msgpackKind, func(strict bool) codec.Handle {
+		h := new(codec.MsgpackHandle)
+		h.WriteExt = true
+		setBasicHandle(&h.BasicHandle, strict)
+		return h
+	})
+	//CBORCodec ...application/cbor
+	CBORCodec Codec = newBinaryCodec("application/cbor", cborKind, func(strict bool) codec.Handle {
+		h := new(codec.CborHandle)
+		setBasicHandle(&h.BasicHandle, strict)
+		return h
+	})
+
+	defaultCodecs = []Codec{JSONCodec, MsgpackCodec, CBORCodec}
+
+	//contentTypeAliases ...其他常用的 Content-Type
+	contentTypeAliases = map[string]string{
+		"application/json-rpc":    contentType,
+		"application/jsonrequest": contentType,
+		"application/x-msgpack":   "application/msgpack",
+	}
+)
+
+type (
+	//Codec ...请求与响应的编码, 根据请求的 Content-Type 选择, 响应使用相同的编码
+	/**
+	RPCMessage 中 ID, Params, Result 等原始字段的内容为消息所使用的编码,
+	方法返回 json.RawMessage 时, 视为已经按照该编码编码的结果
+	*/
+	Codec interface {
+		ContentType() string
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+		//Kind ...编码后的值的类型: reflect.Slice 数组, reflect.Map 对象, reflect.Invalid 为null或空, 其他为标量
+		Kind(raw []byte) reflect.Kind
+	}
+	//Encoder ...
+	Encoder interface{ Encode(v interface{}) error }
+	//StreamCodec ...可以直接编码到 io.Writer 的编码, 流式响应需要编码实现该接口, 否则仍然先编码到内存
+	StreamCodec interface {
+		Codec
+		NewEncoder(w io.Writer) Encoder
+	}
+	//strictUnmarshaler ...对象解码到结构体时, 不允许未知的字段
+	strictUnmarshaler interface {
+		unmarshalStrict(data []byte, v interface{}) error
+	}
+
+	jsonCodec struct{}
+
+	//binaryCodec ...基于 github.com/ugorji/go/codec 的二进制编码
+	binaryCodec struct {
+		contentType string
+		kind        func(b byte) reflect.Kind
+		handle      codec.Handle
+		strict      codec.Handle
+	}
+	//wireMessage ...二进制编码中的 RPCMessage, 原始字段使用 codec.Raw
+	wireMessage struct {
+		ID      codec.Raw `codec:"id,omitempty"`
+		Version string    `codec:"jsonrpc,omitempty"`
+		Method  string    `codec:"method,omitempty"`
+		Params  codec.Raw `codec:"params,omitempty"`
+		Result  codec.Raw `codec:"result,omitempty"`
+		Error   *Error    `codec:"error,omitempty"`
+	}
+	//wireStream ...二进制编码中的 streamMessage
+	wireStream struct {
+		ID      codec.Raw   `codec:"id"`
+		Version string      `codec:"jsonrpc"`
+		Result  interface{} `codec:"result"`
+	}
+	binaryEncoder struct{ enc *codec.Encoder }
+)
+
+func (jsonCodec) ContentType() string { return contentType }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (jsonCodec) Kind(raw []byte) reflect.Kind {
+	switch firstChar(raw) {
+	case '[':
+		return reflect.Slice
+	case '{':
+		return reflect.Map
+	case 0, 'n':
+		return reflect.Invalid
+	case '"':
+		return reflect.String
+	case 't', 'f':
+		return reflect.Bool
+	default:
+		return reflect.Float64
+	}
+}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+
+func (jsonCodec) unmarshalStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// newBinaryCodec ...
+func newBinaryCodec(contentType string, kind func(b byte) reflect.Kind,
+	newHandle func(strict bool) codec.Handle) *binaryCodec {
+	return &binaryCodec{contentType: contentType, kind: kind, handle: newHandle(false), strict: newHandle(true)}
+}
+
+// setBasicHandle ...原始字段原样写入; 解码到 interface{} 时使用 map[string]interface{} 与 string
+func setBasicHandle(h *codec.BasicHandle, strict bool) {
+	h.Raw = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.ErrorIfNoField = strict
+}
+
+func (c *binaryCodec) ContentType() string { return c.contentType }
+
+func (c *binaryCodec) Marshal(v interface{}) (out []byte, err error) {
+	err = codec.NewEncoderBytes(&out, c.handle).Encode(toWire(v))
+	return
+}
+
+func (c *binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	return c.unmarshal(c.handle, data, v)
+}
+
+func (c *binaryCodec) Kind(raw []byte) reflect.Kind {
+	if len(raw) == 0 {
+		return reflect.Invalid
+	}
+	return c.kind(raw[0])
+}
+
+func (c *binaryCodec) NewEncoder(w io.Writer) Encoder {
+	return &binaryEncoder{enc: codec.NewEncoder(w, c.handle)}
+}
+
+func (e *binaryEncoder) Encode(v interface{}) error { return e.enc.Encode(toWire(v)) }
+
+func (c *binaryCodec) unmarshalStrict(data []byte, v interface{}) error {
+	return c.unmarshal(c.strict, data, v)
+}
+
+// unmarshal ...
+func (c *binaryCodec) unmarshal(h codec.Handle, data []byte, v interface{}) (err error) {
+	dec := codec.NewDecoderBytes(data, h)
+	switch val := v.(type) {
+	case *RPCMessage:
+		msg := new(wireMessage)
+		if err = dec.Decode(msg); err == nil {
+			*val = *msg.message()
+		}
+	case *[]*RPCMessage:
+		var list []*wireMessage
+		if err = dec.Decode(&list); err == nil {
+			*val = make([]*RPCMessage, len(list))
+			for i, msg := range list {
+				(*val)[i] = msg.message()
+			}
+		}
+	case *[]json.RawMessage:
+		var list []codec.Raw
+		if err = dec.Decode(&list); err == nil {
+			*val = make([]json.RawMessage, len(list))
+			for i, raw := range list {
+				(*val)[i] = json.RawMessage(raw)
+			}
+		}
+	case *map[string]json.RawMessage:
+		var fields map[string]codec.Raw
+		if err = dec.Decode(&fields); err == nil {
+			*val = make(map[string]json.RawMessage, len(fields))
+			for k, raw := range fields {
+				(*val)[k] = json.RawMessage(raw)
+			}
+		}
+	default:
+		err = dec.Decode(v)
+	}
+	return
+}
+
+// message ...
+func (m *wireMessage) message() *RPCMessage {
+	return &RPCMessage{
+		ID:      json.RawMessage(m.ID),
+		Version: m.Version,
+		Method:  m.Method,
+		Params:  json.RawMessage(m.Params),
+		Result:  json.RawMessage(m.Result),
+		Error:   m.Error,
+	}
+}
+
+// toWire ...原始字段转换为 codec.Raw, 编码时原样写入
+func toWire(v interface{}) interface{} {
+	switch val := v.(type) {
+	case *RPCMessage:
+		return &wireMessage{
+			ID:      codec.Raw(val.ID),
+			Version: val.Version,
+			Method:  val.Method,
+			Params:  codec.Raw(val.Params),
+			Result:  codec.Raw(val.Result),
+			Error:   val.Error,
+		}
+	case []*RPCMessage:
+		list := make([]interface{}, len(val))
+		for i, msg := range val {
+			list[i] = toWire(msg)
+		}
+		return list
+	case *streamMessage:
+		return &wireStream{ID: codec.Raw(val.ID), Version: val.Version, Result: toWire(val.Result)}
+	case json.RawMessage:
+		return codec.Raw(val)
+	default:
+		return v
+	}
+}
+
+// msgpackKind ...https://github.com/msgpack/msgpack/blob/master/spec.md#formats
+func msgpackKind(b byte) reflect.Kind {
+	switch {
+	case b >= 0x90 && b <= 0x9f, b == 0xdc, b == 0xdd:
+		return reflect.Slice
+	case b >= 0x80 && b <= 0x8f, b == 0xde, b == 0xdf:
+		return reflect.Map
+	case b == 0xc0:
+		return reflect.Invalid
+	case b >= 0xa0 && b <= 0xbf, b >= 0xd9 && b <= 0xdb, b >= 0xc4 && b <= 0xc6:
+		return reflect.String
+	case b == 0xc2, b == 0xc3:
+		return reflect.Bool
+	default:
+		return reflect.Float64
+	}
+}
+
+// cborKind ...https://www.rfc-editor.org/rfc/rfc8949#section-3.1
+func cborKind(b byte) reflect.Kind {
+	switch b >> 5 {
+	case 2, 3:
+		return reflect.String
+	case 4:
+		return reflect.Slice
+	case 5:
+		return reflect.Map
+	case 6:
+		return reflect.Interface
+	case 7:
+		switch b {
+		case 0xf4, 0xf5:
+			return reflect.Bool
+		case 0xf6, 0xf7:
+			return reflect.Invalid
+		}
+	}
+	return reflect.Float64
+}
+
+// codecByMediaType ...
+func (o *Option) codecByMediaType(mt string) Codec {
+	if alias, ok := contentTypeAliases[mt]; ok {
+		mt = alias
+	}
+	for _, list := range [][]Codec{o.Codecs, defaultCodecs} {
+		for _, c := range list {
+			if c.ContentType() == mt {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// requestCodec ...根据 Content-Type 选择编码, 不支持时返回nil
+func (o *Option) requestCodec(header string) Codec {
+	mt, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return nil
+	}
+	return o.codecByMediaType(mt)
+}
+
+// acceptable ...Accept 为空或包含编码的类型时返回true; 否则只有明确要求服务器不支持的编码(application/*)时返回false,
+// 其他情况(如 text/html)与不检查 Accept 时相同, 使用请求的编码
+func (o *Option) acceptable(accept string, c Codec) bool {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return true
+	}
+	major, _, _ := strings.Cut(c.ContentType(), "/")
+	unsupported := false
+	for _, item := range strings.Split(accept, ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		if alias, ok := contentTypeAliases[mt]; ok {
+			mt = alias
+		}
+		if mt == c.ContentType() || mt == "*/*" || mt == major+"/*" {
+			return true
+		}
+		if strings.HasPrefix(mt, "application/") && o.codecByMediaType(mt) == nil {
+			unsupported = true
+		}
+	}
+	return !unsupported
+}
@@ -0,0 +1,90 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodecClient(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t, nil))
+	defer srv.Close()
+	for _, c := range []Codec{JSONCodec, MsgpackCodec, CBORCodec} {
+		cl := NewClient(srv.URL).SetCodec(c)
+		var sum int
+		if err := cl.Call(context.Background(), "test.add", &sum, 1, 2); err != nil || sum != 3 {
+			t.Errorf("%s: add = %d, %v", c.ContentType(), sum, err)
+		}
+		var echo string
+		elems := []*BatchElem{{Method: "test.echo", Args: []interface{}{"x"}, Result: &echo}, {Method: "test.none"}}
+		if err := cl.BatchCall(context.Background(), elems); err != nil {
+			t.Fatalf("%s: %v", c.ContentType(), err)
+		}
+		if e, ok := elems[1].Error.(*Error); echo != "x" || !ok || e.Code != ErrNoMethod {
+			t.Errorf("%s: batch echo=%q error=%v", c.ContentType(), echo, elems[1].Error)
+		}
+	}
+}
+
+func TestCodecNegotiation(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		contentType string
+		accept      string
+		status      int
+		respType    string
+	}{
+		{"application/json", "", http.StatusOK, "application/json"},
+		{"application/json-rpc", "application/json", http.StatusOK, "application/json"},
+		{"application/json", "text/html", http.StatusOK, "application/json"},
+		{"application/json", "text/html, application/xml;q=0.9, */*;q=0.8", http.StatusOK, "application/json"},
+		{"application/json", "application/xml", http.StatusNotAcceptable, ""},
+		{"application/x-msgpack", "application/*", http.StatusOK, "application/msgpack"},
+		{"application/cbor", "", http.StatusOK, "application/cbor"},
+		{"text/plain", "", http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		c := s.Opt().requestCodec(tt.contentType)
+		body := []byte(`{"id":1,"method":"test.echo","params":["x"]}`)
+		if c != nil && c != JSONCodec {
+			body, _ = c.Marshal(map[string]interface{}{"id": 1, "method": "test.echo", "params": []string{"x"}})
+		}
+		r := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(string(body)))
+		r.Header.Set("Content-Type", tt.contentType)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.status || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.respType) {
+			t.Errorf("%s accept %q: status %d content type %q, want %d %s", tt.contentType, tt.accept, w.Code,
+				w.Header().Get("Content-Type"), tt.status, tt.respType)
+		}
+	}
+}
+
+func TestCodecKind(t *testing.T) {
+	values := []struct {
+		val  interface{}
+		kind reflect.Kind
+	}{
+		{[]int{1}, reflect.Slice},
+		{map[string]int{"a": 1}, reflect.Map},
+		{nil, reflect.Invalid},
+		{"s", reflect.String},
+		{true, reflect.Bool},
+		{1, reflect.Float64},
+	}
+	for _, c := range []Codec{JSONCodec, MsgpackCodec, CBORCodec} {
+		for _, v := range values {
+			raw, err := c.Marshal(v.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Kind(raw); got != v.kind {
+				t.Errorf("%s: Kind(%v) = %s, want %s", c.ContentType(), v.val, got, v.kind)
+			}
+		}
+	}
+}
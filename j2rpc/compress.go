@@ -0,0 +1,107 @@
+package j2rpc
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// nopWriteCloser ...不压缩时原样写入
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// respond ...写出 HTTP 响应; 流式响应直接编码到 w, 其他响应超过 CompressMinLength 时按 Accept-Encoding 压缩
+func (s *server) respond(w http.ResponseWriter, r *http.Request, c Codec, val interface{}) {
+	if len(w.Header().Get("Status-Written")) != 0 {
+		return
+	}
+	if msg, ok := val.(*RPCMessage); ok && msg.result != nil {
+		if sc, ok := c.(StreamCodec); ok {
+			s.stream(w, r, sc, msg)
+			return
+		}
+		msg.encodeResult()
+	}
+
+	bts, err := c.Marshal(val)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	encoding := ""
+	if min := s.opt.compressMinLength(); min >= 0 && len(bts) >= min {
+		encoding = acceptEncoding(r.Header.Get("Accept-Encoding"))
+	}
+	setContentType(w, c)
+	cw := compressWriter(w, encoding)
+	AbortWriteHeader(w, http.StatusOK)
+	_, _ = cw.Write(bts)
+	_ = cw.Close()
+}
+
+// stream ...信封与结果一次编码到 w, 编码出错时响应已经部分写出, 只能记录日志
+func (s *server) stream(w http.ResponseWriter, r *http.Request, c StreamCodec, msg *RPCMessage) {
+	encoding := ""
+	if s.opt.compressMinLength() >= 0 {
+		encoding = acceptEncoding(r.Header.Get("Accept-Encoding"))
+	}
+	setContentType(w, c)
+	cw := compressWriter(w, encoding)
+	AbortWriteHeader(w, http.StatusOK)
+	defer func() { _ = cw.Close() }()
+
+	err := c.NewEncoder(cw).Encode(&streamMessage{ID: msg.ID, Version: vsn, Result: msg.result})
+	if err != nil {
+		s.logger.Errorf("[Stream] id %s: %s", BytesToString(msg.ID), err.Error())
+	}
+}
+
+// compressWriter ...设置 Content-Encoding, encoding 为空时不压缩
+func compressWriter(w http.ResponseWriter, encoding string) io.WriteCloser {
+	switch encoding {
+	case "br", "gzip":
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.Header().Del("Content-Length")
+	}
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "gzip":
+		return gzip.NewWriter(w)
+	default:
+		return nopWriteCloser{w}
+	}
+}
+
+// acceptEncoding ...根据 Accept-Encoding 选择压缩方式, br 优先于 gzip; 不支持时返回空
+func acceptEncoding(header string) string {
+	var br, gz bool
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v <= 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "br":
+			br = true
+		case "gzip", "x-gzip", "*":
+			gz = true
+		}
+	}
+	switch {
+	case br:
+		return "br"
+	case gz:
+		return "gzip"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,145 @@
+package j2rpc
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"*", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"GZIP;q=0.5, BR;q=0.8", "br"},
+		{"br;q=0, gzip", "gzip"},
+		{"gzip;q=0", ""},
+		{"gzip;q=bad", "gzip"},
+	}
+	for _, tt := range tests {
+		if got := acceptEncoding(tt.header); got != tt.want {
+			t.Errorf("acceptEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// decodeEncoded ...按 Content-Encoding 解压响应
+func decodeEncoded(t *testing.T, w *httptest.ResponseRecorder) *testResponse {
+	t.Helper()
+	var r io.Reader = w.Body
+	switch w.Header().Get("Content-Encoding") {
+	case "br":
+		r = brotli.NewReader(r)
+	case "gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	bts, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := new(testResponse)
+	if err = json.Unmarshal(bts, resp); err != nil {
+		t.Fatalf("decode %q: %v", bts, err)
+	}
+	return resp
+}
+
+func TestRespondCompress(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+	body := `{"id":1,"method":"test.echo","params":["` + large + `"]}`
+	tests := []struct {
+		name     string
+		min      int
+		body     string
+		accept   string
+		encoding string
+	}{
+		{"br", 0, body, "gzip, br", "br"},
+		{"gzip", 0, body, "gzip", "gzip"},
+		{"no accept", 0, body, "", ""},
+		{"small", 0, `{"id":1,"method":"test.echo","params":["x"]}`, "gzip, br", ""},
+		{"disabled", -1, body, "gzip, br", ""},
+	}
+	for _, tt := range tests {
+		s := newTestServer(t, &Option{SnakeNamespace: true, CompressMinLength: tt.min})
+		w := postRPC(s, tt.body, "Accept-Encoding", tt.accept)
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, got, tt.encoding)
+		}
+		if vary := w.Header().Get("Vary"); (len(tt.encoding) > 0) != (vary == "Accept-Encoding") {
+			t.Errorf("%s: Vary = %q", tt.name, vary)
+		}
+		resp := decodeEncoded(t, w)
+		var result string
+		if err := json.Unmarshal(resp.Result, &result); err != nil || resp.Error != nil {
+			t.Fatalf("%s: result %s, error %v", tt.name, resp.Result, resp.Error)
+		}
+		if tt.body == body && result != large {
+			t.Errorf("%s: result length %d, want %d", tt.name, len(result), len(large))
+		}
+	}
+}
+
+// TestStreamResponse ...流式响应与信封一起编码, 不受 CompressMinLength 限制
+func TestStreamResponse(t *testing.T) {
+	opt := &Option{SnakeNamespace: true}
+	opt.AddStream([]string{"test.echo"})
+	s := newTestServer(t, opt)
+	for _, accept := range []string{"", "gzip", "br"} {
+		w := postRPC(s, `{"id":7,"method":"test.echo","params":["streamed"]}`, "Accept-Encoding", accept)
+		if got := w.Header().Get("Content-Encoding"); got != accept {
+			t.Errorf("accept %q: Content-Encoding = %q", accept, got)
+		}
+		resp := decodeEncoded(t, w)
+		if string(resp.ID) != "7" || string(resp.Result) != `"streamed"` || resp.Error != nil {
+			t.Errorf("accept %q: id %s result %s error %v", accept, resp.ID, resp.Result, resp.Error)
+		}
+	}
+
+	//批量请求中仍然先编码
+	w := postRPC(s, `[{"id":1,"method":"test.echo","params":["a"]},{"id":2,"method":"test.add","params":[1,2]}]`)
+	if list := decodeBatch(t, w); len(list) != 2 || string(list[0].Result) != `"a"` || string(list[1].Result) != "3" {
+		t.Errorf("batch = %s", w.Body.String())
+	}
+}
+
+// TestRequestTooLarge ...声明了 Content-Length 时返回 413, 未声明时读取超过限制返回 ErrInvalidRequest
+func TestRequestTooLarge(t *testing.T) {
+	s := newTestServer(t, &Option{SnakeNamespace: true, MaxRequestContentLength: 64})
+	body := `{"id":1,"method":"test.echo","params":["` + strings.Repeat("x", 64) + `"]}`
+	w := postRPC(s, body)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("content length: status %d body %q", w.Code, w.Body.String())
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/jsonrpc", io.MultiReader(strings.NewReader(body)))
+	r.Header.Set("Content-Type", "application/json")
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if resp := decodeResponse(t, w); resp.errorCode() != ErrInvalidRequest ||
+		!strings.Contains(resp.Error.Message, "too large") {
+		t.Errorf("chunked: %s", w.Body.String())
+	}
+
+	if w = postRPC(s, `{"id":1,"method":"test.echo","params":["x"]}`); decodeResponse(t, w).errorCode() != 0 {
+		t.Errorf("under the limit: %s", w.Body.String())
+	}
+}
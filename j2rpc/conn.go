@@ -0,0 +1,294 @@
+package j2rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+type (
+	//transport ...连接的消息读写, websocket 中为一个消息帧, ServeConn 中为一行
+	transport interface {
+		readMessage() ([]byte, error)
+		writeMessage(msg []byte) error
+		close() error
+	}
+
+	//rpcConn ...一个长连接(websocket, tcp, unix, stdio), 请求按 id 复用同一连接, 订阅的通知也通过该连接推送
+	rpcConn struct {
+		s *server
+		//连接的原始 context 与 request(websocket 升级请求, 其他连接为nil), 传递给前置中间件
+		base context.Context
+		r    *http.Request
+
+		tr     transport
+		ctx    context.Context
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+		wmu    sync.Mutex
+		//优雅关闭中, 读取结束后等待处理中的消息
+		draining int32
+
+		smu    sync.Mutex
+		closed bool
+		subs   map[string]*Subscription
+	}
+	//connFrame ...一个消息(单个或批量请求), 响应写出之后才激活其中创建的订阅
+	connFrame struct {
+		conn *rpcConn
+
+		mu      sync.Mutex
+		flushed bool
+		subs    []*Subscription
+	}
+	connFrameKey struct{}
+
+	//discardResponseWriter ...每个消息独立的 ResponseWriter, 供前置中间件使用, 写入的内容被丢弃
+	discardResponseWriter struct{ header http.Header }
+
+	//lineTransport ...以换行符分隔的 JSON 消息
+	lineTransport struct {
+		rwc   io.ReadWriteCloser
+		rd    *bufio.Reader
+		limit int64
+	}
+	//stdio ...
+	stdio struct{}
+)
+
+var (
+	errMessageTooLarge = errors.New("message too large")
+	//errConnClosed ...连接已经关闭, 不再等待正在处理的消息
+	errConnClosed = errors.New("connection closed")
+)
+
+// ServeConn ...在 rwc 上处理以换行符分隔的 JSON-RPC 消息(单个或批量请求), 直到连接断开或 ctx 取消;
+// 与 websocket 相同, 响应通过 id 与请求对应, 支持订阅; 用于 tcp, unix socket 与 stdio(见 StdioConn)
+func (s *server) ServeConn(ctx context.Context, rwc io.ReadWriteCloser) {
+	if atomic.LoadInt32(&s.run) == 0 {
+		_ = rwc.Close()
+		return
+	}
+	s.newConn(ctx, nil, newLineTransport(rwc, s.opt.maxRequestContentLength())).serve()
+}
+
+// StdioConn ...标准输入输出组成的连接, 用于 CLI 插件: s.ServeConn(ctx, j2rpc.StdioConn())
+func StdioConn() io.ReadWriteCloser { return stdio{} }
+
+func (stdio) Read(p []byte) (int, error) { return os.Stdin.Read(p) }
+
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+func (stdio) Close() error { return os.Stdin.Close() }
+
+// newConn ...ctx 取消时关闭连接
+func (s *server) newConn(ctx context.Context, r *http.Request, tr transport) *rpcConn {
+	c := &rpcConn{s: s, base: ctx, r: r, tr: tr, subs: make(map[string]*Subscription)}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	go func() {
+		<-c.ctx.Done()
+		_ = c.tr.close()
+	}()
+	return c
+}
+
+// serve ...读取消息直到连接断开, 每个消息在独立的协程中处理
+func (c *rpcConn) serve() {
+	defer c.close()
+	for {
+		body, err := c.tr.readMessage()
+		if err != nil {
+			switch {
+			//优雅关闭或对方关闭了写入(如 stdin 结束), 仍然可以写出正在处理的消息的响应
+			case atomic.LoadInt32(&c.draining) == 1, errors.Is(err, io.EOF):
+				c.wg.Wait()
+			case !errors.Is(err, errConnClosed):
+				c.s.logger.Debugf("[Conn] read: %s", err.Error())
+			}
+			return
+		}
+		if atomic.LoadInt32(&c.s.run) == 0 {
+			return
+		}
+		c.wg.Add(1)
+		go c.serveFrame(body)
+	}
+}
+
+// serveFrame ...
+func (c *rpcConn) serveFrame(body []byte) {
+	defer c.wg.Done()
+	f := &connFrame{conn: c}
+	defer f.flush()
+	//处理消息时 panic, 不能影响连接上的其他消息; 响应使用请求的 id, 客户端才能对应到等待中的调用
+	defer func() {
+		if p := recover(); p != nil {
+			err := c.s.stack(p, "[Conn] frame")
+			id, ok := frameID(body)
+			if !ok {
+				return
+			}
+			if err = c.write((&RPCMessage{ID: id}).setError(err).output()); err != nil {
+				c.s.logger.Debugf("[Conn] write: %s", err.Error())
+			}
+		}
+	}()
+
+	ctx := context.WithValue(c.ctx, connFrameKey{}, f)
+	w := &discardResponseWriter{header: make(http.Header)}
+	resp := c.s.process(JSONCodec, c.base, ctx, w, c.r, body)
+	if resp == nil {
+		return
+	}
+	if msg, ok := resp.(*RPCMessage); ok {
+		msg.encodeResult()
+	}
+	if err := c.write(resp); err != nil {
+		c.s.logger.Debugf("[Conn] write: %s", err.Error())
+	}
+}
+
+// frameID ...单个请求的 id, 无法解析时为nil(响应的 id 为 null); 通知与批量请求(元素各自处理 panic)返回false
+func frameID(body []byte) (json.RawMessage, bool) {
+	if isBatch(JSONCodec, body) {
+		return nil, false
+	}
+	msg := new(RPCMessage)
+	if err := JSONCodec.Unmarshal(body, msg); err != nil || !msg.hasValidID() {
+		return nil, err != nil || len(msg.ID) > 0
+	}
+	return msg.ID, true
+}
+
+// write ...连接不支持并发写入
+func (c *rpcConn) write(val interface{}) (err error) {
+	bts, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.tr.writeMessage(bts)
+}
+
+// drain ...优雅关闭: 停止读取新的消息, 处理中的消息结束后关闭连接; 不支持读取期限的连接直接关闭
+func (c *rpcConn) drain() {
+	atomic.StoreInt32(&c.draining, 1)
+	if lt, ok := c.tr.(*lineTransport); ok {
+		if d, ok := lt.rwc.(interface{ SetReadDeadline(time.Time) error }); ok && d.SetReadDeadline(time.Now()) == nil {
+			return
+		}
+	}
+	c.cancel()
+}
+
+// close ...取消所有订阅, 等待正在处理的消息结束
+func (c *rpcConn) close() {
+	c.cancel()
+	c.smu.Lock()
+	c.closed = true
+	subs := make([]*Subscription, 0, len(c.subs))
+	for _, sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	c.smu.Unlock()
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	_ = c.tr.close()
+	//方法可能使用升级请求的 *gin.Context, 必须在 handler 返回之前结束
+	c.wg.Wait()
+}
+
+// subscription ...
+func (c *rpcConn) subscription(id string) *Subscription {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return c.subs[id]
+}
+
+// removeSubscription ...
+func (c *rpcConn) removeSubscription(id string) {
+	c.smu.Lock()
+	delete(c.subs, id)
+	c.smu.Unlock()
+}
+
+// add ...响应已经写出时返回false, 此时客户端无法得知订阅id
+func (f *connFrame) add(sub *Subscription) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.flushed {
+		return false
+	}
+	f.subs = append(f.subs, sub)
+	return true
+}
+
+// flush ...响应写出之后, 激活订阅
+func (f *connFrame) flush() {
+	f.mu.Lock()
+	f.flushed = true
+	subs := f.subs
+	f.mu.Unlock()
+	for _, sub := range subs {
+		sub.activate()
+	}
+}
+
+// frameFromContext ...
+func frameFromContext(ctx context.Context) *connFrame {
+	f, _ := ctx.Value(connFrameKey{}).(*connFrame)
+	return f
+}
+
+func (w *discardResponseWriter) Header() http.Header { return w.header }
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func (w *discardResponseWriter) WriteHeader(int) {}
+
+// newLineTransport ...
+func newLineTransport(rwc io.ReadWriteCloser, limit int64) *lineTransport {
+	return &lineTransport{rwc: rwc, rd: bufio.NewReader(rwc), limit: limit}
+}
+
+// readMessage ...读取一行, 忽略空行; 最后一行可以没有换行符
+func (t *lineTransport) readMessage() ([]byte, error) {
+	for {
+		var line []byte
+		for {
+			chunk, err := t.rd.ReadSlice('\n')
+			line = append(line, chunk...)
+			if int64(len(line)) > t.limit {
+				return nil, errMessageTooLarge
+			}
+			if errors.Is(err, bufio.ErrBufferFull) {
+				continue
+			}
+			if err != nil && (!errors.Is(err, io.EOF) || len(bytes.TrimSpace(line)) == 0) {
+				return nil, err
+			}
+			break
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+func (t *lineTransport) writeMessage(msg []byte) error {
+	_, err := t.rwc.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *lineTransport) close() error { return t.rwc.Close() }
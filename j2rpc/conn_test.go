@@ -0,0 +1,211 @@
+package j2rpc
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// connClient ...按行读写的测试连接
+type connClient struct {
+	t  *testing.T
+	c  net.Conn
+	rd *bufio.Reader
+}
+
+func newConnClient(t *testing.T, c net.Conn) *connClient {
+	return &connClient{t: t, c: c, rd: bufio.NewReader(c)}
+}
+
+// call ...写入一行, 读取一行响应
+func (c *connClient) call(line string) *testResponse {
+	c.t.Helper()
+	msg := new(testResponse)
+	c.callInto(line, msg)
+	return msg
+}
+
+// callInto ...响应解码到 v
+func (c *connClient) callInto(line string, v interface{}) {
+	c.t.Helper()
+	c.send(line)
+	_ = c.c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := c.rd.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("%s: read: %v", line, err)
+	}
+	if err = JSONCodec.Unmarshal(resp, v); err != nil {
+		c.t.Fatalf("%s: decode %q: %v", line, resp, err)
+	}
+}
+
+func (c *connClient) send(line string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.c, line+"\n"); err != nil {
+		c.t.Fatalf("%s: write: %v", line, err)
+	}
+}
+
+func TestServeConn(t *testing.T) {
+	api := new(testAPI)
+	opt := &Option{SnakeNamespace: true}
+	opt.AddBeforeMiddleware(func(_ context.Context, method string) { panic("before " + method) }, []string{"test.fail"})
+	opt.AddMiddleware(func(ctx context.Context, req *RPCMessage, next Handler) *RPCMessage {
+		panic("middleware")
+	}, []string{"test.old"})
+	s := newTestServer(t, opt, api)
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.ServeConn(context.Background(), server)
+		close(done)
+	}()
+	c := newConnClient(t, client)
+
+	if resp := c.call(`{"id":1,"method":"test.add","params":[1,2]}`); string(resp.Result) != `3` {
+		t.Errorf("add: %s %+v", resp.Result, resp.Error)
+	}
+	c.send(`{"method":"test.notify"}`)
+	c.send(``)
+	var list []*testResponse
+	c.callInto(`[{"id":2,"method":"test.echo","params":["x"]},{"method":"test.notify"}]`, &list)
+	if len(list) != 1 || string(list[0].Result) != `"x"` {
+		t.Errorf("batch: %d responses", len(list))
+	}
+	if resp := c.call(`{"id":3,`); resp.errorCode() != ErrParse {
+		t.Errorf("parse: %+v", resp.Error)
+	}
+	//前置中间件与中间件 panic 时返回错误, 连接可以继续使用
+	if resp := c.call(`{"id":4,"method":"test.fail"}`); resp.errorCode() != ErrInternal {
+		t.Errorf("before middleware panic: %+v", resp.Error)
+	}
+	if resp := c.call(`{"id":5,"method":"test.old"}`); resp.errorCode() != ErrInternal {
+		t.Errorf("middleware panic: %+v", resp.Error)
+	}
+	if resp := c.call(`{"id":6,"method":"test.echo","params":["y"]}`); string(resp.Result) != `"y"` {
+		t.Errorf("echo after panic: %s %+v", resp.Result, resp.Error)
+	}
+	_ = client.Close()
+	<-done
+	if api.notified != 2 {
+		t.Errorf("notified %d times, want 2", api.notified)
+	}
+}
+
+type panicAPI struct{}
+
+func (*panicAPI) Boom() string { panic("boom") }
+
+func (*panicAPI) Echo(s string) string { return s }
+
+// TestServeConnPanicID ...panic 的错误响应带请求的 id, 复用连接的客户端才能对应到等待中的调用
+func TestServeConnPanicID(t *testing.T) {
+	var panics int32
+	opt := &Option{SnakeNamespace: true, Audit: io.Discard}
+	//审计在中间件链之外执行, 第一次调用的 panic 由连接的 recover 处理
+	opt.AuditUser = func(context.Context) string {
+		if atomic.AddInt32(&panics, 1) == 1 {
+			panic("audit user")
+		}
+		return ""
+	}
+	s := newTestServer(t, opt, new(panicAPI))
+	server, client := net.Pipe()
+	go s.ServeConn(context.Background(), server)
+	defer func() { _ = client.Close() }()
+	c := newConnClient(t, client)
+
+	if resp := c.call(`{"id":"a1","method":"test.echo","params":["x"]}`); string(resp.ID) != `"a1"` ||
+		resp.errorCode() != ErrInternal {
+		t.Errorf("frame panic: id %s error %+v", resp.ID, resp.Error)
+	}
+	if resp := c.call(`{"id":"a2","method":"test.boom"}`); string(resp.ID) != `"a2"` || resp.errorCode() != ErrInternal {
+		t.Errorf("method panic: id %s error %+v", resp.ID, resp.Error)
+	}
+	if resp := c.call(`{"id":"a3","method":"test.echo","params":["y"]}`); string(resp.ID) != `"a3"` ||
+		string(resp.Result) != `"y"` {
+		t.Errorf("echo after panic: id %s result %s error %+v", resp.ID, resp.Result, resp.Error)
+	}
+}
+
+func TestFrameID(t *testing.T) {
+	tests := []struct {
+		body string
+		id   string
+		ok   bool
+	}{
+		{`{"id":1,"method":"a.b"}`, `1`, true},
+		{`{"id":"x","method":"a.b"}`, `"x"`, true},
+		{`{"method":"a.b"}`, ``, false},
+		{`{"id":[1],"method":"a.b"}`, ``, true},
+		{`{"id":1,`, ``, true},
+		{`[{"id":1,"method":"a.b"}]`, ``, false},
+	}
+	for _, tt := range tests {
+		id, ok := frameID([]byte(tt.body))
+		if string(id) != tt.id || ok != tt.ok {
+			t.Errorf("frameID(%s) = %s, %t, want %s, %t", tt.body, id, ok, tt.id, tt.ok)
+		}
+	}
+}
+
+func TestLineTransport(t *testing.T) {
+	tr := newLineTransport(nopCloser{strings.NewReader("a\n\n  \nbb\r\nccc")}, 8)
+	for _, want := range []string{"a", "bb", "ccc"} {
+		line, err := tr.readMessage()
+		if err != nil || string(line) != want {
+			t.Fatalf("readMessage = %q, %v, want %q", line, err, want)
+		}
+	}
+	if _, err := tr.readMessage(); err != io.EOF {
+		t.Errorf("readMessage at end: %v", err)
+	}
+	tr = newLineTransport(nopCloser{strings.NewReader(strings.Repeat("x", 20) + "\n")}, 8)
+	if _, err := tr.readMessage(); err != errMessageTooLarge {
+		t.Errorf("readMessage too large: %v", err)
+	}
+}
+
+func TestListener(t *testing.T) {
+	s := newTestServer(t, nil)
+	ln, err := s.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- ln.Serve() }()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	c := newConnClient(t, conn)
+	if resp := c.call(`{"id":1,"method":"test.echo","params":["x"]}`); string(resp.Result) != `"x"` {
+		t.Fatalf("echo: %s %+v", resp.Result, resp.Error)
+	}
+
+	//优雅关闭时, 正在处理的请求仍然返回响应
+	c.send(`{"id":2,"method":"test.sleep","params":[50]}`)
+	time.Sleep(10 * time.Millisecond)
+	if err = ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	line, err := c.rd.ReadString('\n')
+	if err != nil || !strings.Contains(line, `"done"`) {
+		t.Errorf("in-flight response = %q, %v", line, err)
+	}
+	if err = <-served; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
+
+type nopCloser struct{ io.Reader }
+
+func (nopCloser) Write(p []byte) (int, error) { return len(p), nil }
+
+func (nopCloser) Close() error { return nil }
@@ -37,4 +37,47 @@ func main() {
 	log.Fatalln(s1.Run(":300"))
 }
 
+example2: by-name params
+
+type bb struct{}
+
+type listArgs struct {
+	Page      int `json:"page"`
+	PageCount int `json:"page_count"`
+}
+
+//List {"id":1,"method":"bb.list","params":{"page":1,"page_count":10}}
+func (b *bb) List(args *listArgs) (data interface{}) { return args }
+
+//Get {"id":1,"method":"bb.get","params":{"user_id":1,"page":2}}
+func (b *bb) Get(userID int64, page *int) (data interface{}) { return userID }
+
+//J2rpcParamNames ...
+func (b *bb) J2rpcParamNames() map[string][]string {
+	return map[string][]string{"Get": {"user_id", "page"}}
+}
+
+example3: around middleware
+
+opt.AddMiddleware(func(ctx context.Context, req *j2rpc.RPCMessage, next j2rpc.Handler) *j2rpc.RPCMessage {
+	start := time.Now()
+	resp := next(ctx, req)
+	log.Printf("%s use: %s", req.Method, time.Since(start))
+	return resp
+}, []string{`^aa\.`})
+
+example4: access rules
+
+type app struct {
+	User *user `inject:"" j2rpc:"auth"`
+}
+
+func (u *user) J2rpcAccessRules() map[string]j2rpc.AccessRule {
+	return map[string]j2rpc.AccessRule{"Login": {}, "Delete": {Auth: true, Roles: []string{"admin"}}}
+}
+
+opt.Authenticator = token.J2rpcAuthenticator(func(ctx context.Context, id int64) ([]string, error) {
+	return userRoles(ctx, id)
+})
+
 */
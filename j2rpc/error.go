@@ -1,5 +1,15 @@
 package j2rpc
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/atcharles/gof/v2/g2util"
+)
+
 // declared ...
 const (
 	ErrParse          ErrorCode = -32700
@@ -8,11 +18,30 @@ const (
 	ErrBadParams      ErrorCode = -32602
 	ErrInternal       ErrorCode = -32603
 	ErrServer         ErrorCode = -32000
+	ErrTimeout        ErrorCode = -32001
 
 	ErrAuthorization ErrorCode = 401
 	ErrForbidden     ErrorCode = 403
+	ErrNotFound      ErrorCode = 404
 )
 
+// internalErrorMessage ...生产模式中代替内部错误的信息
+const internalErrorMessage = "internal server error"
+
+// errorCodeNames ...错误码名称, 用于生成客户端代码
+var errorCodeNames = map[ErrorCode]string{
+	ErrParse:          "Parse",
+	ErrInvalidRequest: "InvalidRequest",
+	ErrNoMethod:       "NoMethod",
+	ErrBadParams:      "BadParams",
+	ErrInternal:       "Internal",
+	ErrServer:         "Server",
+	ErrTimeout:        "Timeout",
+	ErrAuthorization:  "Authorization",
+	ErrForbidden:      "Forbidden",
+	ErrNotFound:       "NotFound",
+}
+
 // Error ... Error codes
 type Error struct {
 	Code    ErrorCode   `json:"code"`
@@ -30,6 +59,21 @@ type (
 	TokenError string
 	//ForbiddenError ...
 	ForbiddenError string
+
+	//ErrorMapping ...方法返回的错误转换为响应的错误, 见 Option.MapError, Option.MapErrorType
+	ErrorMapping struct {
+		Code ErrorCode
+		//返回给客户端的信息, 为空时使用错误的信息
+		Message string
+		//响应的 Data, 为nil时不输出
+		Data func(err error) interface{}
+	}
+	//errorMapping ...target 按 errors.Is 匹配, 否则 typ 按 errors.As 匹配
+	errorMapping struct {
+		ErrorMapping
+		target error
+		typ    reflect.Type
+	}
 )
 
 func (t TokenError) Error() string { return string(t) }
@@ -44,3 +88,69 @@ func NewError(code ErrorCode, Msg string, data ...interface{}) *Error {
 	}
 	return ee
 }
+
+// match ...
+func (m *errorMapping) match(err error) bool {
+	if m.target != nil {
+		return errors.Is(err, m.target)
+	}
+	return errors.As(err, reflect.New(m.typ).Interface())
+}
+
+// toError ...
+func (m *errorMapping) toError(err error) *Error {
+	e := NewError(m.Code, m.Message)
+	if len(e.Message) == 0 {
+		e.Message = err.Error()
+	}
+	if m.Data != nil {
+		e.Data = m.Data(err)
+	}
+	return e
+}
+
+// fieldsError ...字段错误列表组成的 ErrBadParams
+func fieldsError(list []g2util.FieldError) *Error {
+	msgs := make([]string, len(list))
+	for i, f := range list {
+		msgs[i] = f.Message
+	}
+	return NewError(ErrBadParams, strings.Join(msgs, ","), list)
+}
+
+// mapError ...按 Option 中注册的顺序转换方法返回的错误; 校验错误转换为 ErrBadParams;
+// 生产模式中其他的错误只返回通用的信息, 原始错误与请求id记录在日志中
+func (s *server) mapError(w http.ResponseWriter, resp *RPCMessage) *RPCMessage {
+	if resp.Error == nil || resp.err == nil {
+		return resp
+	}
+	err := resp.err
+	for i := range s.opt.ErrorMappings {
+		if m := &s.opt.ErrorMappings[i]; m.match(err) {
+			resp.Error = m.toError(err)
+			return resp
+		}
+	}
+	switch err.(type) {
+	case *Error, TokenError, ForbiddenError:
+		//ErrInternal 为方法 panic 等内部错误, 已经记录了日志
+		if s.opt.Production && resp.Error.Code == ErrInternal {
+			resp.Error = NewError(ErrInternal, internalErrorMessage)
+		}
+		return resp
+	}
+	if list, ok := g2util.Valid.TranslateFields(err); ok {
+		resp.Error = fieldsError(list)
+		return resp
+	}
+	if !s.opt.Production {
+		return resp
+	}
+	msg := fmt.Sprintf("[Error] %s: %s", resp.Method, err.Error())
+	if requestID := w.Header().Get("request-id"); len(requestID) > 0 {
+		msg = fmt.Sprintf("[Request-ID:%s] %s", requestID, msg)
+	}
+	s.logger.Errorf("%s", msg)
+	resp.Error = NewError(ErrServer, internalErrorMessage)
+	return resp
+}
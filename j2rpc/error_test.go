@@ -0,0 +1,148 @@
+package j2rpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+var errTestNotFound = errors.New("record not found")
+
+// testCodeError ...按 errors.As 转换的错误类型
+type testCodeError struct{ field string }
+
+func (e *testCodeError) Error() string { return "conflict on " + e.field }
+
+type errorAPI struct{}
+
+func (*errorAPI) Err(kind string) error {
+	switch kind {
+	case "not_found":
+		return fmt.Errorf("load user: %w", errTestNotFound)
+	case "typed":
+		return fmt.Errorf("save: %w", &testCodeError{field: "email"})
+	case "both":
+		return fmt.Errorf("%w: %w", &testCodeError{field: "name"}, errTestNotFound)
+	case "rpc":
+		return NewError(ErrForbidden, "no access")
+	case "token":
+		return TokenError("token expired")
+	case "panic":
+		panic("db password=secret")
+	default:
+		return errors.New("dial tcp 10.0.0.1:3306: password=secret")
+	}
+}
+
+// newErrorServer ...logs 为服务的日志
+func newErrorServer(t *testing.T, production bool) (RPCServer, *bytes.Buffer) {
+	opt := &Option{SnakeNamespace: true, Production: production}
+	opt.MapErrorType(new(*testCodeError), ErrorMapping{Code: 409,
+		Data: func(err error) interface{} {
+			var e *testCodeError
+			errors.As(err, &e)
+			return map[string]string{"field": e.field}
+		}})
+	opt.MapError(errTestNotFound, ErrorMapping{Code: ErrNotFound, Message: "数据不存在"})
+	s := newTestServer(t, opt, new(errorAPI))
+	logs := new(bytes.Buffer)
+	s.Logger().SetOutput(logs)
+	return s, logs
+}
+
+// callErr ...响应头中预先设置 request-id, 与 g2gin 的中间件相同
+func callErr(t *testing.T, s RPCServer, kind string) *testResponse {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/jsonrpc",
+		strings.NewReader(`{"id":1,"method":"test.err","params":["`+kind+`"]}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	w.Header().Set("request-id", "req-"+kind)
+	s.ServeHTTP(w, r)
+	return decodeResponse(t, w)
+}
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		kind string
+		code ErrorCode
+		msg  string
+		data string
+	}{
+		{"not_found", ErrNotFound, "数据不存在", ""},
+		{"typed", 409, "save: conflict on email", `{"field":"email"}`},
+		//按注册的顺序匹配第一个
+		{"both", 409, "conflict on name: record not found", `{"field":"name"}`},
+		{"rpc", ErrForbidden, "no access", ""},
+		{"token", ErrAuthorization, "token expired", ""},
+	}
+	for _, production := range []bool{false, true} {
+		s, _ := newErrorServer(t, production)
+		for _, tt := range tests {
+			resp := callErr(t, s, tt.kind)
+			if resp.errorCode() != tt.code || resp.Error.Message != tt.msg {
+				t.Errorf("production %t %s: error %+v, want %d %q", production, tt.kind, resp.Error, tt.code, tt.msg)
+				continue
+			}
+			data := ""
+			if resp.Error.Data != nil {
+				bts, _ := json.Marshal(resp.Error.Data)
+				data = string(bts)
+			}
+			if data != tt.data {
+				t.Errorf("production %t %s: data %s, want %s", production, tt.kind, data, tt.data)
+			}
+		}
+	}
+}
+
+// TestProductionHidesErrors ...生产模式中没有转换的错误与 panic 只返回通用的信息, 原始错误记录在日志中
+func TestProductionHidesErrors(t *testing.T) {
+	s, _ := newErrorServer(t, false)
+	if resp := callErr(t, s, "plain"); resp.errorCode() != ErrServer ||
+		!strings.Contains(resp.Error.Message, "password=secret") {
+		t.Errorf("development: %+v", resp.Error)
+	}
+
+	s, logs := newErrorServer(t, true)
+	tests := []struct {
+		kind string
+		code ErrorCode
+	}{
+		{"plain", ErrServer},
+		{"panic", ErrInternal},
+	}
+	for _, tt := range tests {
+		resp := callErr(t, s, tt.kind)
+		if resp.errorCode() != tt.code || resp.Error.Message != internalErrorMessage || resp.Error.Data != nil {
+			t.Errorf("%s: error %+v, want %d %q", tt.kind, resp.Error, tt.code, internalErrorMessage)
+		}
+	}
+	if !strings.Contains(logs.String(), "[Request-ID:req-plain] [Error] test.err: dial tcp") {
+		t.Errorf("original error not logged with the request id:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "db password=secret") {
+		t.Errorf("panic not logged:\n%s", logs.String())
+	}
+}
+
+func TestMapErrorTypeTarget(t *testing.T) {
+	for _, target := range []interface{}{nil, testCodeError{}, new(testCodeError), new(string)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MapErrorType(%T) should panic", target)
+				}
+			}()
+			new(Option).MapErrorType(target, ErrorMapping{})
+		}()
+	}
+	//指向接口的指针
+	new(Option).MapErrorType(new(interface{ Timeout() bool }), ErrorMapping{Code: ErrTimeout})
+}
@@ -1,30 +1,28 @@
 package j2rpc
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"mime"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 	"unsafe"
 
+	"github.com/atcharles/gof/v2/g2util"
 	"github.com/atcharles/gof/v2/json"
 )
 
 //------------------------------ [segmentation] --------------------------------
 
 const (
-	maxRequestContentLength = 1024 * 1024 * 5
+	defaultMaxRequestContentLength = 1024 * 1024 * 5
+	defaultCompressMinLength       = 1024
 
 	contentType = "application/json"
 )
 
-// https://www.jsonrpc.org/historical/json-rpc-over-http.html#id13
-var acceptedContentTypes = []string{contentType, "application/json-rpc", "application/jsonrequest"}
-
 // AbortWriteHeader ...
 func AbortWriteHeader(w http.ResponseWriter, code int) {
 	w.WriteHeader(code)
@@ -85,6 +83,42 @@ func StringToBytes(s string) []byte {
 	return *(*[]byte)(unsafe.Pointer(&bp))
 }
 
+// firstChar returns the first non-whitespace character of raw, 0 if raw is blank
+func firstChar(raw []byte) byte {
+	for _, c := range raw {
+		// skip insignificant whitespace (http://www.ietf.org/rfc/rfc4627.txt)
+		if c == 0x20 || c == 0x09 || c == 0x0a || c == 0x0d {
+			continue
+		}
+		return c
+	}
+	return 0
+}
+
+// isBatch returns true when raw is an array
+func isBatch(c Codec, raw []byte) bool { return c.Kind(raw) == reflect.Slice }
+
+// isObjectType ...struct, pointer to struct or map with string keys
+func isObjectType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct || (t.Kind() == reflect.Map && t.Key().Kind() == reflect.String)
+}
+
+// fieldTags ...obj 中带有 tagName 标签的非空指针字段, 以字段值为 key
+func fieldTags(obj interface{}, tagName string) map[interface{}]string {
+	tags := make(map[interface{}]string)
+	val := g2util.ValueIndirect(reflect.ValueOf(obj))
+	for i := 0; i < val.NumField(); i++ {
+		tag, has := val.Type().Field(i).Tag.Lookup(tagName)
+		if fv := val.Field(i); has && fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.CanInterface() {
+			tags[fv.Interface()] = tag
+		}
+	}
+	return tags
+}
+
 func isErrorType(t reflect.Type) bool {
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -92,44 +126,156 @@ func isErrorType(t reflect.Type) bool {
 	return t.Implements(errorType)
 }
 
-func parseArgumentArray(dec *json.Decoder, types []reflect.Type) ([]reflect.Value, error) {
-	args := make([]reflect.Value, 0, len(types))
-	for i := 0; dec.More(); i++ {
-		if i >= len(types) {
-			return args, fmt.Errorf("too many arguments, want at most %d", len(types))
+// parseArguments ...params 为对象时按名称解析, 否则按位置解析
+func parseArguments(c Codec, rawArgs json.RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	if c.Kind(rawArgs) == reflect.Map {
+		return parseNamedArguments(c, rawArgs, types, names)
+	}
+	return parsePositionalArguments(c, rawArgs, types)
+}
+
+// parseNamedArguments parses by-name params. When the method declares no param names,
+// the params object is decoded into its single struct (or map) argument.
+func parseNamedArguments(c Codec, rawArgs json.RawMessage, types []reflect.Type, names []string) ([]reflect.Value, error) {
+	if len(names) == 0 {
+		if len(types) != 1 || !isObjectType(types[0]) {
+			return nil, errors.New("named params are not supported by the method")
+		}
+		agv := reflect.New(types[0])
+		unmarshal := c.Unmarshal
+		if sc, ok := c.(strictUnmarshaler); ok {
+			unmarshal = sc.unmarshalStrict
+		}
+		if err := unmarshal(rawArgs, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid params: %v", err)
+		}
+		if err := checkRequiredFields(c, rawArgs, types[0]); err != nil {
+			return nil, err
+		}
+		return []reflect.Value{agv.Elem()}, nil
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := c.Unmarshal(rawArgs, &fields); err != nil {
+		return nil, fmt.Errorf("invalid params: %v", err)
+	}
+	args := make([]reflect.Value, len(types))
+	for i, name := range names {
+		ctp := types[i]
+		raw, ok := fields[name]
+		delete(fields, name)
+		if !ok || c.Kind(raw) == reflect.Invalid {
+			if ctp.Kind() != reflect.Ptr {
+				return nil, fmt.Errorf("missing value for required param %q", name)
+			}
+			args[i] = reflect.New(ctp.Elem())
+			continue
+		}
+		agv := reflect.New(ctp)
+		if err := c.Unmarshal(raw, agv.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid param %q: %v", name, err)
+		}
+		args[i] = agv.Elem()
+	}
+	if len(fields) > 0 {
+		unknown := make([]string, 0, len(fields))
+		for k := range fields {
+			unknown = append(unknown, k)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown params: %s", strings.Join(unknown, ","))
+	}
+	return args, nil
+}
+
+// checkRequiredFields ...对象解码到结构体时, 与多个参数的缺少必须参数相同, 缺少 validate:"required" 的字段时返回错误
+func checkRequiredFields(c Codec, rawArgs json.RawMessage, t reflect.Type) error {
+	required := requiredFields(t)
+	if len(required) == 0 {
+		return nil
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := c.Unmarshal(rawArgs, &fields); err != nil {
+		return fmt.Errorf("invalid params: %v", err)
+	}
+	for _, name := range required {
+		raw, ok := fields[name]
+		if !ok {
+			//与 json 解码相同, 字段名称不区分大小写
+			for k, v := range fields {
+				if strings.EqualFold(k, name) {
+					raw, ok = v, true
+					break
+				}
+			}
 		}
-		agv := reflect.New(types[i])
-		if err := dec.Decode(agv.Interface()); err != nil {
-			return args, fmt.Errorf("invalid argument %d: %v", i, err)
+		if !ok || c.Kind(raw) == reflect.Invalid {
+			return fmt.Errorf("missing value for required param %q", name)
 		}
-		if agv.IsNil() && types[i].Kind() != reflect.Ptr {
-			return args, fmt.Errorf("missing value for required argument %d", i)
+	}
+	return nil
+}
+
+// requiredFields ...结构体中 validate 标签为 required 的字段的名称, 与 OpenRPC 文档中的 required 相同
+func requiredFields(t reflect.Type) (list []string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, skip := jsonFieldName(f)
+		if skip {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		//匿名嵌入且没有指定json名称的结构体, 字段提升到当前层级
+		if f.Anonymous && ft.Kind() == reflect.Struct && len(name) == 0 {
+			list = append(list, requiredFields(ft)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		if applyValidateTag(new(Schema), ft, f.Tag.Get("validate")) {
+			list = append(list, name)
 		}
-		args = append(args, agv.Elem())
 	}
-	// Read end of args array.
-	_, err := dec.Token()
-	return args, err
+	return
 }
 
 // parsePositionalArguments tries to parse the given args to an array of values with the
 // given types. It returns the parsed values or an error when the args could not be
 // parsed. Missing optional arguments are returned as reflect.Zero|reflect.New values.
-func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]reflect.Value, error) {
-	dec := json.NewDecoder(bytes.NewReader(rawArgs))
+func parsePositionalArguments(c Codec, rawArgs json.RawMessage, types []reflect.Type) ([]reflect.Value, error) {
 	var args []reflect.Value
-	tok, err := dec.Token()
-	switch {
-	case err == io.EOF || (err == nil && tok == nil):
+	switch c.Kind(rawArgs) {
+	case reflect.Invalid:
 		// "params" is optional and may be empty. Also allow "params":null even though it's
 		// not in the spec because our own client used to send it.
-	case err != nil:
-		return nil, err
-	case tok == json.Delim('['):
-		// Read argument array.
-		if args, err = parseArgumentArray(dec, types); err != nil {
+	case reflect.Slice:
+		var raws []json.RawMessage
+		if err := c.Unmarshal(rawArgs, &raws); err != nil {
 			return nil, err
 		}
+		if len(raws) > len(types) {
+			return nil, fmt.Errorf("too many arguments, want at most %d", len(types))
+		}
+		for i, raw := range raws {
+			agv := reflect.New(types[i])
+			if err := c.Unmarshal(raw, agv.Interface()); err != nil {
+				return nil, fmt.Errorf("invalid argument %d: %v", i, err)
+			}
+			args = append(args, agv.Elem())
+		}
 	default:
 		return nil, errors.New("non-array args")
 	}
@@ -146,31 +292,72 @@ func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]
 	return args, nil
 }
 
+// readBody reads the request body, at most limit bytes.
+func readBody(r *http.Request, limit int64) (body []byte, err error) {
+	body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, NewError(ErrParse, err.Error())
+	}
+	//未声明 Content-Length 的请求体(如 chunked)超出限制
+	if int64(len(body)) > limit {
+		return nil, NewError(ErrInvalidRequest, fmt.Sprintf("content length too large (>%d)", limit))
+	}
+	return
+}
+
 // validateRequest returns a non-zero response code and error message if the
-// request is invalid.
-func validateRequest(r *http.Request) (int, error) {
+// request is invalid, otherwise the codec negotiated by Content-Type and Accept.
+func (s *server) validateRequest(r *http.Request) (Codec, int, error) {
 	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
-		return http.StatusMethodNotAllowed, errors.New("method not allowed")
+		return nil, http.StatusMethodNotAllowed, errors.New("method not allowed")
 	}
-	if r.ContentLength > maxRequestContentLength {
-		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxRequestContentLength)
-		return http.StatusRequestEntityTooLarge, err
+	if limit := s.opt.maxRequestContentLength(); r.ContentLength > limit {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, limit)
+		return nil, http.StatusRequestEntityTooLarge, err
 	}
 	// Allow OPTIONS (regardless of content-type)
 	if r.Method == http.MethodOptions {
-		return 0, nil
+		return JSONCodec, 0, nil
 	}
 	// Check content-type
-	if mt, _, err := mime.ParseMediaType(r.Header.Get("content-type")); err == nil {
-		for _, accepted := range acceptedContentTypes {
-			if accepted == mt {
-				return 0, nil
-			}
-		}
+	c := s.opt.requestCodec(r.Header.Get("content-type"))
+	if c == nil {
+		return nil, http.StatusUnsupportedMediaType, errors.New("invalid content type")
+	}
+	if !s.opt.acceptable(r.Header.Get("accept"), c) {
+		return nil, http.StatusNotAcceptable, fmt.Errorf("response content type is %s", c.ContentType())
+	}
+	return c, 0, nil
+}
+
+// writeJSON ...
+func writeJSON(w http.ResponseWriter, val interface{}) { writeCodec(w, JSONCodec, val) }
+
+// writeCodec ...
+func writeCodec(w http.ResponseWriter, c Codec, val interface{}) {
+	if len(w.Header().Get("Status-Written")) != 0 {
+		return
+	}
+
+	bts, err := c.Marshal(val)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	setContentType(w, c)
+	AbortWriteHeader(w, http.StatusOK)
+	n, err := w.Write(bts)
+	_, _ = n, err
+}
+
+// setContentType ...
+func setContentType(w http.ResponseWriter, c Codec) {
+	if c == JSONCodec {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return
 	}
-	// Invalid content-type
-	err := fmt.Errorf("invalid content type, only %s is supported", contentType)
-	return http.StatusUnsupportedMediaType, err
+	w.Header().Set("Content-Type", c.ContentType())
 }
 
 func writeError(w http.ResponseWriter, status int, msg string) {
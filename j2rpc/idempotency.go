@@ -0,0 +1,140 @@
+package j2rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+// IdempotencyKeyHeader ...幂等请求的请求头
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+type (
+	//IdempotencyStore ...保存幂等请求的响应, 实现见 g2db 的 Redis.J2rpcIdempotencyStore 与 g2cache.NewIdempotencyStore
+	IdempotencyStore interface {
+		//Lock ...获取执行锁, 返回持有者的 token, 已被占用时返回空; ttl 之后自动释放, 防止进程退出后无法再次执行
+		Lock(ctx context.Context, key string, ttl time.Duration) (token string, err error)
+		//Unlock ...只释放 token 持有的锁, 锁过期后已被其他请求获取时不释放
+		Unlock(ctx context.Context, key, token string) error
+		//Get ...不存在时返回 nil, nil
+		Get(ctx context.Context, key string) ([]byte, error)
+		Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	}
+
+	//IdempotencyOption ...
+	IdempotencyOption struct {
+		Store IdempotencyStore
+		//响应保存的时间, 为0时为 24 小时
+		TTL time.Duration
+		//执行锁的时间, 为0时为 1 分钟; 应大于方法的超时时间
+		LockTTL time.Duration
+		//等待执行中的相同请求的轮询间隔, 为0时为 100ms
+		PollInterval time.Duration
+		//请求的用户, 不同用户的相同 key 互不影响; 为nil或返回空时只按 key+method 区分
+		User func(ctx context.Context) string
+	}
+
+	//idempotencyRecord ...保存的响应, Params 的摘要用于检查 key 是否被不同的请求重复使用
+	idempotencyRecord struct {
+		Params string `json:"params"`
+		Result []byte `json:"result"`
+	}
+)
+
+// Idempotency ...幂等中间件, 客户端在请求头 Idempotency-Key 中携带唯一的 key
+/**
+相同 key+method+user 的第一个成功响应被保存 TTL, 之后的重复请求直接返回保存的结果;
+执行中的重复请求等待第一个请求结束, 而不是再次执行; 失败的响应不保存, 可以使用相同的 key 重试.
+只对 http 请求有效, websocket 与 ServeConn 中没有每个请求的请求头
+
+opt.AddMiddleware(j2rpc.Idempotency(j2rpc.IdempotencyOption{
+	Store: mysql.Redis.J2rpcIdempotencyStore(),
+	User:  token.J2rpcUser(),
+}), []string{`^payment\.`})
+*/
+func Idempotency(opt IdempotencyOption) Middleware {
+	if opt.TTL <= 0 {
+		opt.TTL = time.Hour * 24
+	}
+	if opt.LockTTL <= 0 {
+		opt.LockTTL = time.Minute
+	}
+	if opt.PollInterval <= 0 {
+		opt.PollInterval = time.Millisecond * 100
+	}
+	return func(ctx context.Context, req *RPCMessage, next Handler) *RPCMessage {
+		r := req.HTTPRequest()
+		if r == nil || req.isNotification() {
+			return next(ctx, req)
+		}
+		idemKey := r.Header.Get(IdempotencyKeyHeader)
+		if len(idemKey) == 0 {
+			return next(ctx, req)
+		}
+		if len(idemKey) > maxIdempotencyKeyLength {
+			return NewResponse(req, nil, NewError(ErrInvalidRequest, IdempotencyKeyHeader+" is too long"))
+		}
+		var user string
+		if opt.User != nil {
+			user = opt.User(ctx)
+		}
+		key := "j2rpc:idem:" + req.Codec().ContentType() + ":" + req.Method + ":" + user + ":" + idemKey
+		sum := sha256.Sum256(req.Params)
+		params := hex.EncodeToString(sum[:])
+
+		replay := func(data []byte) *RPCMessage {
+			rec := new(idempotencyRecord)
+			if err := json.Unmarshal(data, rec); err != nil {
+				return NewResponse(req, nil, NewError(ErrInternal, err.Error()))
+			}
+			if rec.Params != params {
+				return NewResponse(req, nil,
+					NewError(ErrInvalidRequest, IdempotencyKeyHeader+" is already used with different params"))
+			}
+			return NewResponse(req, json.RawMessage(rec.Result), nil)
+		}
+
+		//等待执行中的相同请求
+		var token string
+		for {
+			data, err := opt.Store.Get(ctx, key)
+			if err != nil {
+				return NewResponse(req, nil, NewError(ErrServer, err.Error()))
+			}
+			if data != nil {
+				return replay(data)
+			}
+			token, err = opt.Store.Lock(ctx, key+":lock", opt.LockTTL)
+			if err != nil {
+				return NewResponse(req, nil, NewError(ErrServer, err.Error()))
+			}
+			if len(token) > 0 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return NewResponse(req, nil, NewError(ErrTimeout, ctx.Err().Error()))
+			case <-time.After(opt.PollInterval):
+			}
+		}
+		defer func() { _ = opt.Store.Unlock(context.WithoutCancel(ctx), key+":lock", token) }()
+		//获取锁之前, 第一个请求可能刚刚结束
+		if data, err := opt.Store.Get(ctx, key); err == nil && data != nil {
+			return replay(data)
+		}
+
+		resp := next(ctx, req)
+		if resp == nil || resp.encodeResult().Error != nil {
+			return resp
+		}
+		if data, err := json.Marshal(&idempotencyRecord{Params: params, Result: resp.Result}); err == nil {
+			_ = opt.Store.Set(context.WithoutCancel(ctx), key, data, opt.TTL)
+		}
+		return resp
+	}
+}
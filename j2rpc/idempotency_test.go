@@ -0,0 +1,133 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type (
+	//memIdempotencyStore ...
+	memIdempotencyStore struct {
+		mu    sync.Mutex
+		seq   int
+		locks map[string]string
+		data  map[string][]byte
+	}
+
+	payAPI struct{ calls int32 }
+)
+
+func newMemIdempotencyStore() *memIdempotencyStore {
+	return &memIdempotencyStore{locks: make(map[string]string), data: make(map[string][]byte)}
+}
+
+func (m *memIdempotencyStore) Lock(_ context.Context, key string, _ time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.locks[key]) > 0 {
+		return "", nil
+	}
+	m.seq++
+	m.locks[key] = fmt.Sprintf("token-%d", m.seq)
+	return m.locks[key], nil
+}
+
+func (m *memIdempotencyStore) Unlock(_ context.Context, key, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[key] != token {
+		return fmt.Errorf("unlock %s with token %q, held by %q", key, token, m.locks[key])
+	}
+	delete(m.locks, key)
+	return nil
+}
+
+func (m *memIdempotencyStore) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[key], nil
+}
+
+func (m *memIdempotencyStore) Set(_ context.Context, key string, data []byte, _ time.Duration) error {
+	m.mu.Lock()
+	m.data[key] = data
+	m.mu.Unlock()
+	return nil
+}
+
+// Charge ...返回第几次执行, amount 小于0时失败
+func (p *payAPI) Charge(amount int) (string, error) {
+	n := atomic.AddInt32(&p.calls, 1)
+	time.Sleep(20 * time.Millisecond)
+	if amount < 0 {
+		return "", errors.New("invalid amount")
+	}
+	return fmt.Sprintf("charge %d #%d", amount, n), nil
+}
+
+func TestIdempotency(t *testing.T) {
+	api, store := new(payAPI), newMemIdempotencyStore()
+	opt := &Option{SnakeNamespace: true}
+	opt.AddMiddleware(Idempotency(IdempotencyOption{
+		Store:        store,
+		PollInterval: time.Millisecond,
+		User:         func(ctx context.Context) string { return "u1" },
+	}), []string{`^test\.`})
+	s := newTestServer(t, opt, api)
+	call := func(key, params string) *testResponse {
+		return decodeResponse(t, postRPC(s, `{"id":1,"method":"test.charge","params":`+params+`}`,
+			IdempotencyKeyHeader, key))
+	}
+
+	//并发的相同请求只执行一次
+	var wg sync.WaitGroup
+	results := make([]string, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = string(call("k1", `[10]`).Result)
+		}(i)
+	}
+	wg.Wait()
+	for _, res := range results {
+		if res != `"charge 10 #1"` {
+			t.Errorf("concurrent results = %v", results)
+			break
+		}
+	}
+	if resp := call("k1", `[10]`); string(resp.Result) != `"charge 10 #1"` {
+		t.Errorf("replay: %s %+v", resp.Result, resp.Error)
+	}
+	if resp := call("k1", `[20]`); resp.errorCode() != ErrInvalidRequest {
+		t.Errorf("reused key: %+v", resp.Error)
+	}
+	if resp := call("k2", `[20]`); string(resp.Result) != `"charge 20 #2"` {
+		t.Errorf("new key: %s %+v", resp.Result, resp.Error)
+	}
+	//失败的响应不保存, 可以使用相同的 key 重试
+	call("k3", `[-1]`)
+	call("k3", `[-1]`)
+	if n := atomic.LoadInt32(&api.calls); n != 4 {
+		t.Errorf("calls = %d, want 4", n)
+	}
+	//没有 key 时每次都执行
+	postRPC(s, `{"id":1,"method":"test.charge","params":[1]}`)
+	postRPC(s, `{"id":1,"method":"test.charge","params":[1]}`)
+	if n := atomic.LoadInt32(&api.calls); n != 6 {
+		t.Errorf("calls = %d, want 6", n)
+	}
+	if resp := call(strings.Repeat("k", maxIdempotencyKeyLength+1), `[1]`); resp.errorCode() != ErrInvalidRequest {
+		t.Errorf("long key: %+v", resp.Error)
+	}
+	//Unlock 使用 Lock 返回的 token
+	if len(store.locks) > 0 {
+		t.Errorf("locks not released: %v", store.locks)
+	}
+}
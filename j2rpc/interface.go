@@ -2,6 +2,8 @@ package j2rpc
 
 import (
 	"context"
+	"io"
+	"net"
 	"net/http"
 	"reflect"
 
@@ -13,6 +15,8 @@ type (
 	ItfConstructor interface{ Constructor() }
 	//ItfExcludeMethod ...
 	ItfExcludeMethod interface{ ExcludeMethod() []string }
+	//ItfParamNames ...声明方法的参数名称, 用于按名称传递参数(params为对象), key为方法名
+	ItfParamNames interface{ J2rpcParamNames() map[string][]string }
 	//RPCServer ...
 	RPCServer interface {
 		Opt() *Option
@@ -22,6 +26,16 @@ type (
 		RegisterForApp(app interface{})
 		Register(receiver interface{}, names ...string)
 		Handler(ctx context.Context, w http.ResponseWriter, r *http.Request)
+		Discover() *OpenRPCDocument
+		ServeDiscover(w http.ResponseWriter, r *http.Request)
+		ServeWebsocket(ctx context.Context, w http.ResponseWriter, r *http.Request)
+		ServeConn(ctx context.Context, rwc io.ReadWriteCloser)
+		Listen(network, address string) (*Listener, error)
+		NewListener(ln net.Listener) *Listener
+		TypeScript(w io.Writer) error
+		WriteMetrics(w io.Writer) error
+		ServeMetrics(w http.ResponseWriter, r *http.Request)
+		InvalidateCache(names ...string)
 		Stop()
 	}
 	//ItfNamespaceName ...
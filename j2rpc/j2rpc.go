@@ -2,6 +2,7 @@ package j2rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -9,9 +10,9 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/atcharles/gof/v2/g2util"
-	"github.com/atcharles/gof/v2/json"
 )
 
 const (
@@ -34,6 +35,12 @@ type (
 		run      int32
 		services map[string]service
 		logger   g2util.LevelLogger
+		metrics  *metrics
+
+		//结果缓存的版本, 见 InvalidateCache
+		cacheMu    sync.RWMutex
+		cacheEpoch int64
+		cacheGens  map[string]uint64
 
 		excludeMethods []string
 	}
@@ -54,7 +61,8 @@ func (s *server) Handler(ctx context.Context, w http.ResponseWriter, r *http.Req
 	if len(w.Header().Get("Status-Written")) != 0 {
 		return
 	}
-	if code, err := validateRequest(r); err != nil {
+	c, code, err := s.validateRequest(r)
+	if err != nil {
 		http.Error(w, err.Error(), code)
 		return
 	}
@@ -62,15 +70,21 @@ func (s *server) Handler(ctx context.Context, w http.ResponseWriter, r *http.Req
 	// from the declared content-type
 	w.Header().Set("x-content-type-options", "nosniff")
 
-	msg := new(RPCMessage)
-	if err := s.handle(ctx, w, r, msg); err != nil {
-		msg = msg.setError(err)
+	body, err := readBody(r, s.opt.maxRequestContentLength())
+	if err != nil {
+		errorResponse(c, err).writeResponse(w)
+		return
 	}
-	msg.output().writeResponse(w)
-	requestID := w.Header().Get("request-id")
-	if len(requestID) > 0 {
-		s.logger.Debugf("[Request-ID:%s] %s", requestID, g2util.JSONDump(msg))
+	resp := s.process(c, ctx, ctx, w, r, body)
+	//通知或全部为通知的批量请求, 不返回任何内容
+	if resp == nil {
+		if len(w.Header().Get("Status-Written")) == 0 {
+			AbortWriteHeader(w, http.StatusNoContent)
+		}
+		return
 	}
+	s.respond(w, r, c, resp)
+	s.debugResponse(w, resp)
 }
 
 func (s *server) Logger() g2util.LevelLogger { return s.logger }
@@ -78,8 +92,11 @@ func (s *server) Logger() g2util.LevelLogger { return s.logger }
 // Opt ...
 func (s *server) Opt() *Option { return s.opt }
 
-// Register ...
-func (s *server) Register(receiver interface{}, names ...string) {
+// Register ...names[0] 为命名空间, names[1] 为版本, 如 Register(new(UserV2), "user", "v2") 注册为 v2.user
+func (s *server) Register(receiver interface{}, names ...string) { s.register(receiver, nil, names...) }
+
+// register ...rule 为命名空间默认的访问规则
+func (s *server) register(receiver interface{}, rule *AccessRule, names ...string) {
 	var _fnGetServiceName = func(rv interface{}) string {
 		rvv := g2util.ValueIndirect(reflect.ValueOf(rv))
 		var name string
@@ -92,7 +109,14 @@ func (s *server) Register(receiver interface{}, names ...string) {
 		if len(name) == 0 {
 			name = s.formatName(rvv.Type().Name())
 		}
-		return name
+		var version string
+		if len(names) > 1 {
+			version = names[1]
+		}
+		if v1, ok := rv.(ItfVersion); ok {
+			version = v1.J2rpcVersion()
+		}
+		return versionedName(version, name)
 	}
 	serviceName := _fnGetServiceName(receiver)
 
@@ -100,7 +124,7 @@ func (s *server) Register(receiver interface{}, names ...string) {
 		consVal.Constructor()
 	}
 
-	callbacks := s.suitableCallbacks(receiver)
+	callbacks := s.suitableCallbacks(receiver, rule)
 	if len(callbacks) == 0 {
 		return
 	}
@@ -134,8 +158,9 @@ func (s *server) RegisterForApp(app interface{}) {
 		s.excludeMethods = _app.ExcludeMethod()
 	}
 	namespaces := g2util.ObjectTagInstances(app, "j2rpc")
+	tags := fieldTags(app, "j2rpc")
 	for _, namespace := range namespaces {
-		s.Register(namespace)
+		s.register(namespace, parseAccessTag(tags[namespace]))
 	}
 }
 
@@ -159,12 +184,16 @@ func (s *server) Stop() {
 	}
 }
 
-// formatName ...
+// formatName ...带版本的命名空间(v2.UserInfo)按段转换
 func (s *server) formatName(name string) string {
-	if s.opt.SnakeNamespace {
-		name = SnakeString(name)
+	if !s.opt.SnakeNamespace {
+		return name
 	}
-	return name
+	parts := strings.Split(name, splitMethodSeparator)
+	for i, part := range parts {
+		parts[i] = SnakeString(part)
+	}
+	return strings.Join(parts, splitMethodSeparator)
 }
 
 // getCallBack ...
@@ -183,39 +212,134 @@ func (s *server) getCallBack(elem []string) (cbk callback, err error) {
 	return
 }
 
-// handle ...
-func (s *server) handle(ctx context.Context, w http.ResponseWriter, r *http.Request, msg *RPCMessage) (err error) {
-	err = json.NewDecoder(r.Body).Decode(msg)
-	_ = r.Body.Close()
-	if err != nil {
-		err = NewError(ErrParse, err.Error())
-		return
+// debugResponse ...
+func (s *server) debugResponse(w http.ResponseWriter, val interface{}) {
+	requestID := w.Header().Get("request-id")
+	if len(requestID) > 0 {
+		s.logger.Debugf("[Request-ID:%s] %s", requestID, g2util.JSONDump(val))
 	}
+}
 
-	if !msg.hasValidID() {
-		err = NewError(ErrInvalidRequest, "id is invalid")
-		return
+// process ...使用编码 c 处理请求体, 返回 *RPCMessage, []*RPCMessage; 没有需要返回的内容时为nil
+// base 为请求的原始 context(如 *gin.Context), 传递给前置中间件; ctx 传递给中间件链与方法
+func (s *server) process(c Codec, base, ctx context.Context, w http.ResponseWriter, r *http.Request,
+	body []byte) interface{} {
+	if isBatch(c, body) {
+		return s.processBatch(c, base, ctx, w, r, body)
+	}
+	msg := new(RPCMessage)
+	err := c.Unmarshal(body, msg)
+	msg.codec = c
+	switch {
+	case err != nil:
+		return errorResponse(c, NewError(ErrParse, err.Error()))
+	case msg.isNotification():
+		s.handleNotification(base, ctx, w, r, msg)
+		return nil
+	case !msg.hasValidID():
+		msg.setError(NewError(ErrInvalidRequest, "id is invalid"))
+		msg.ID = nil
+		return msg.output()
+	default:
+		return s.handle(base, ctx, w, r, msg).output()
 	}
+}
+
+// handleNotification ...执行通知请求, 不返回响应, 错误只记录日志
+func (s *server) handleNotification(base, ctx context.Context, w http.ResponseWriter, r *http.Request,
+	msg *RPCMessage) {
+	if resp := s.handle(base, ctx, w, r, msg); resp.Error != nil {
+		s.logger.Errorf("[Notification] %s: %s", msg.Method, resp.Error.Error())
+	}
+}
+
+// handle ...经过中间件链执行请求, 返回响应
+func (s *server) handle(base, ctx context.Context, w http.ResponseWriter, r *http.Request,
+	msg *RPCMessage) *RPCMessage {
+	start := time.Now()
 	elem, err := msg.methods()
 	if err != nil {
-		return
+		resp := NewResponse(msg, nil, err)
+		s.observe(nil, start, resp)
+		s.audit(base, ctx, w, r, msg, nil, start, resp)
+		return resp
 	}
 	for i, e2 := range elem {
 		//elem[i] = s.formatName(CamelString(e2))
 		elem[i] = s.formatName(e2)
 	}
+	elem[0] = s.resolveVersion(r, elem[0])
 	msg.Method = strings.Join(elem, splitMethodSeparator)
+	msg.request = r
 
-	cbk, err := s.getCallBack(elem)
-	if err != nil {
-		return
+	stream := s.opt.stream(msg.Method)
+	invoke := func(c context.Context, req *RPCMessage) *RPCMessage {
+		res, e := s.invoke(base, c, w, r, req, elem)
+		if stream && e == nil {
+			return newStreamResponse(req, res)
+		}
+		return NewResponse(req, res, e)
+	}
+	resp := s.callChain(ctx, msg, invoke)
+	if resp == nil {
+		resp = NewResponse(msg, nil, nil)
 	}
+	resp = s.mapError(w, resp)
+	s.observe(elem, start, resp)
+	s.audit(base, ctx, w, r, msg, elem, start, resp)
+	return resp
+}
 
-	if err = s.opt.beforeMiddlewareAction(ctx, msg.Method, w, r); err != nil {
+// callChain ...经过中间件链执行, 中间件 panic 时返回 ErrInternal 的响应
+func (s *server) callChain(ctx context.Context, msg *RPCMessage, h Handler) (resp *RPCMessage) {
+	defer func() {
+		if p := recover(); p != nil {
+			resp = NewResponse(msg, nil, s.stack(p, msg.Method))
+		}
+	}()
+	return s.opt.chain(msg.Method, h)(ctx, msg)
+}
+
+// callTimeout ...方法设置了超时时间时, 只有方法本身在新的 goroutine 中执行, 超时后立即返回 ErrTimeout
+// 方法通过 ctx 感知取消; 超时返回后方法仍可能在后台执行, 此时不再使用 w 与 base
+func (s *server) callTimeout(base, ctx context.Context, method string, cbk callback,
+	args []reflect.Value) (interface{}, error) {
+	d := s.opt.timeout(method)
+	if d <= 0 {
+		return cbk.call(cbk.context(base, ctx), args)
+	}
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	type result struct {
+		res interface{}
+		err error
+	}
+	done := make(chan result, 1)
+	callCtx := cbk.detachedContext(base, ctx)
+	go func() {
+		res, err := cbk.call(callCtx, args)
+		done <- result{res, err}
+	}()
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, NewError(ErrTimeout, fmt.Sprintf("method %s timed out after %s", method, d))
+		}
+		return nil, NewError(ErrServer, ctx.Err().Error())
+	}
+}
+
+// invoke ...执行前置中间件与回调, base 为请求的原始 context
+func (s *server) invoke(base, ctx context.Context, w http.ResponseWriter, r *http.Request, msg *RPCMessage,
+	elem []string) (res interface{}, err error) {
+	cbk, err := s.getCallBack(elem)
+	if err != nil {
 		return
 	}
 
-	//Catch panic while running the callback.
+	//Catch panic while running the before middlewares and the callback.
 	defer func() {
 		if p := recover(); p != nil {
 			err = s.stack(p, cbk.methodName)
@@ -223,29 +347,30 @@ func (s *server) handle(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		}
 	}()
 
-	callArgs, err := parsePositionalArguments(msg.Params, cbk.argTypes)
-	if err != nil {
-		err = NewError(ErrBadParams, err.Error())
+	if err = s.checkDeprecation(w, elem[0], msg.Method, cbk); err != nil {
 		return
 	}
-
-	res, err := cbk.call(ctx, callArgs)
-	if err != nil {
+	if err = s.opt.beforeMiddlewareAction(base, msg.Method, w, r); err != nil {
 		return
 	}
-	val := reflect.ValueOf(res)
-	if !val.IsValid() {
+	if err = s.checkAccess(base, msg.Method, cbk.access); err != nil {
 		return
 	}
-	if val.IsZero() {
+
+	callArgs, err := parseArguments(msg.Codec(), msg.Params, cbk.argTypes, cbk.argNames)
+	if err != nil {
+		err = NewError(ErrBadParams, err.Error())
 		return
 	}
-	answer, err := json.Marshal(res)
-	if err != nil {
+	if err = cbk.validateArguments(callArgs); err != nil {
 		return
 	}
-	msg.Result = answer
-	return
+	if cbk.isSub {
+		return s.subscribe(base, ctx, cbk, callArgs)
+	}
+	return s.callCached(ctx, msg, elem, cbk, func() (interface{}, error) {
+		return s.callTimeout(base, ctx, msg.Method, cbk, callArgs)
+	})
 }
 
 // stack ...
@@ -258,14 +383,42 @@ func (s *server) stack(recover interface{}, methodName string) error {
 	return NewError(ErrInternal, msg)
 }
 
-// suitableCallbacks ...
-func (s *server) suitableCallbacks(receiver interface{}) (callbacks map[string]callback) {
+// suitableCallbacks ...rule 为没有在 ItfAccessRules 中声明的方法的访问规则
+func (s *server) suitableCallbacks(receiver interface{}, rule *AccessRule) (callbacks map[string]callback) {
 	callbacks = make(map[string]callback)
 
-	var skipMethods = append([]string{"Constructor", "ExcludeMethod"}, s.excludeMethods...)
+	var skipMethods = append(
+		[]string{
+			"Constructor", "ExcludeMethod", "J2rpcParamNames", "J2rpcNamespaceName", "J2rpcAccessRules",
+			"J2rpcNoValidate", "J2rpcVersion", "J2rpcDeprecated", "J2rpcCache",
+		},
+		s.excludeMethods...,
+	)
 	if exv, ok := receiver.(ItfExcludeMethod); ok {
 		skipMethods = append(skipMethods, exv.ExcludeMethod()...)
 	}
+	var paramNames map[string][]string
+	if pnv, ok := receiver.(ItfParamNames); ok {
+		paramNames = pnv.J2rpcParamNames()
+	}
+	var accessRules map[string]AccessRule
+	if arv, ok := receiver.(ItfAccessRules); ok {
+		accessRules = arv.J2rpcAccessRules()
+	}
+	noValidate := make(map[string]bool)
+	if nvv, ok := receiver.(ItfNoValidate); ok {
+		for _, name := range nvv.J2rpcNoValidate() {
+			noValidate[name] = true
+		}
+	}
+	var deprecated map[string]Deprecation
+	if dv, ok := receiver.(ItfDeprecated); ok {
+		deprecated = dv.J2rpcDeprecated()
+	}
+	var cacheRules map[string]CacheRule
+	if cv, ok := receiver.(ItfCacheable); ok {
+		cacheRules = cv.J2rpcCache()
+	}
 	var _fn1InSkips = func(m1 string) bool {
 		for _, method := range skipMethods {
 			if m1 == method {
@@ -288,6 +441,30 @@ func (s *server) suitableCallbacks(receiver interface{}) (callbacks map[string]c
 		if ok := c.makeArgTypes(); !ok {
 			return
 		}
+		if argNames, ok := paramNames[method.Name]; ok {
+			if len(argNames) != len(c.argTypes) {
+				panic(fmt.Sprintf("method [%s] declares %d param names, want %d",
+					method.Name, len(argNames), len(c.argTypes)))
+			}
+			c.argNames = argNames
+		}
+		c.access = rule
+		if r, ok := accessRules[method.Name]; ok {
+			c.access = &r
+		}
+		if !noValidate[method.Name] {
+			c.validArgs = validateArgs(c.argTypes)
+		}
+		if d, ok := deprecated[method.Name]; ok {
+			c.deprecation = &d
+		} else if d, ok := deprecated["*"]; ok {
+			c.deprecation = &d
+		}
+		if r, ok := cacheRules[method.Name]; ok {
+			c.cache = &r
+		} else if r, ok := cacheRules["*"]; ok {
+			c.cache = &r
+		}
 		callbacks[s.formatName(method.Name)] = c
 	}
 
@@ -301,8 +478,10 @@ func (s *server) suitableCallbacks(receiver interface{}) (callbacks map[string]c
 // New ...
 func New(opts ...*Option) RPCServer {
 	s := &server{
-		run:    1,
-		logger: g2util.NewLevelLogger("[STDOUT]"),
+		run:        1,
+		logger:     g2util.NewLevelLogger("[STDOUT]"),
+		cacheEpoch: time.Now().UnixNano(),
+		cacheGens:  make(map[string]uint64),
 	}
 	if len(opts) > 0 && opts[0] != nil {
 		s.opt = opts[0]
@@ -310,5 +489,9 @@ func New(opts ...*Option) RPCServer {
 	if s.opt == nil {
 		s.opt = SnakeOption
 	}
+	if !s.opt.DisableMetrics {
+		s.metrics = newMetrics(s.opt.MetricsBuckets)
+	}
+	s.registerRPCService()
 	return s
 }
@@ -0,0 +1,183 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+type (
+	testAPI struct{ notified int32 }
+
+	testResponse struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+)
+
+func (*testAPI) Echo(s string) string { return s }
+
+func (*testAPI) Add(a, b int) int { return a + b }
+
+func (*testAPI) Fail() error { return errors.New("failed") }
+
+func (*testAPI) Old() string { return "old" }
+
+func (t *testAPI) Notify() { atomic.AddInt32(&t.notified, 1) }
+
+// Sleep ...ctx 取消时提前返回
+func (*testAPI) Sleep(ctx context.Context, ms int) (string, error) {
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return "done", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (*testAPI) J2rpcDeprecated() map[string]Deprecation {
+	return map[string]Deprecation{"Old": {Message: "use test.echo"}}
+}
+
+// newTestServer ...opt 为nil时使用新的 SnakeNamespace 选项, 避免修改全局的 SnakeOption
+func newTestServer(t *testing.T, opt *Option, receivers ...interface{}) RPCServer {
+	t.Helper()
+	if opt == nil {
+		opt = &Option{SnakeNamespace: true}
+	}
+	opt.DisableMetrics = true
+	s := New(opt)
+	s.Logger().SetOutput(new(bytes.Buffer))
+	if len(receivers) == 0 {
+		receivers = append(receivers, new(testAPI))
+	}
+	for _, rcv := range receivers {
+		s.Register(rcv, "test")
+	}
+	return s
+}
+
+// postRPC ...
+func postRPC(s RPCServer, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// handleRPC ...ctx 为请求的原始 context
+func handleRPC(s RPCServer, ctx context.Context, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.Handler(ctx, w, r)
+	return w
+}
+
+// decodeResponse ...
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder) *testResponse {
+	t.Helper()
+	resp := new(testResponse)
+	if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+// decodeBatch ...
+func decodeBatch(t *testing.T, w *httptest.ResponseRecorder) []*testResponse {
+	t.Helper()
+	var list []*testResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return list
+}
+
+// errorCode ...没有错误时为0
+func (r *testResponse) errorCode() ErrorCode {
+	if r.Error == nil {
+		return 0
+	}
+	return r.Error.Code
+}
+
+func TestHandlerCall(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		body   string
+		result string
+		code   ErrorCode
+	}{
+		{`{"id":1,"method":"test.echo","params":["hi"]}`, `"hi"`, 0},
+		{`{"id":1,"method":"test.add","params":[1,2]}`, `3`, 0},
+		{`{"id":1,"method":"test.fail"}`, ``, ErrServer},
+		{`{"id":1,"method":"test.none"}`, ``, ErrNoMethod},
+		{`{"id":1,"method":"test.add","params":["a"]}`, ``, ErrBadParams},
+		{`{"id":[1],"method":"test.echo"}`, ``, ErrInvalidRequest},
+		{`{"id":1,`, ``, ErrParse},
+	}
+	for _, tt := range tests {
+		resp := decodeResponse(t, postRPC(s, tt.body))
+		if resp.errorCode() != tt.code || string(resp.Result) != tt.result {
+			t.Errorf("%s: result=%s error=%+v, want result=%s code=%d", tt.body, resp.Result, resp.Error,
+				tt.result, tt.code)
+		}
+	}
+}
+
+func TestNotification(t *testing.T) {
+	api := new(testAPI)
+	s := newTestServer(t, nil, api)
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"test.notify"}`,
+		`{"method":"test.fail"}`,
+		`{"method":"test.none"}`,
+	} {
+		w := postRPC(s, body)
+		if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+			t.Errorf("%s: status %d body %q, want 204 without body", body, w.Code, w.Body)
+		}
+	}
+	if api.notified != 1 {
+		t.Errorf("notified %d times, want 1", api.notified)
+	}
+}
+
+// TestMiddlewarePanic ...中间件在调用 next 之前或之后 panic 时, 返回带请求 id 的 ErrInternal
+func TestMiddlewarePanic(t *testing.T) {
+	opt := &Option{SnakeNamespace: true}
+	opt.AddMiddleware(func(ctx context.Context, req *RPCMessage, next Handler) *RPCMessage {
+		panic("before next")
+	}, []string{"test.old"})
+	opt.AddMiddleware(func(ctx context.Context, req *RPCMessage, next Handler) *RPCMessage {
+		next(ctx, req)
+		panic("after next")
+	}, []string{"test.echo"})
+	s := newTestServer(t, opt)
+	for _, body := range []string{
+		`{"id":7,"method":"test.old"}`,
+		`{"id":7,"method":"test.echo","params":["x"]}`,
+	} {
+		resp := decodeResponse(t, postRPC(s, body))
+		if string(resp.ID) != `7` || resp.errorCode() != ErrInternal {
+			t.Errorf("%s: id %s error %+v", body, resp.ID, resp.Error)
+		}
+	}
+	if resp := decodeResponse(t, postRPC(s, `{"id":8,"method":"test.add","params":[1,2]}`)); string(resp.Result) != `3` {
+		t.Errorf("add after panic: %s %+v", resp.Result, resp.Error)
+	}
+}
@@ -0,0 +1,129 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Listener ...tcp, unix socket 上的 JSON-RPC 服务, 每个连接使用 ServeConn 处理
+/**
+实现了 g2util.ItfGracefulProcess, 注册到 Graceful 后随程序优雅关闭:
+
+ln, err := jsv.Listen("unix", "/tmp/app.sock")
+if err != nil {
+	return err
+}
+graceful.RegProcessor(ln)
+go func() { _ = ln.Serve() }()
+*/
+type Listener struct {
+	s      *server
+	ln     net.Listener
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	conns  map[*rpcConn]struct{}
+}
+
+// Listen ...network 为 tcp, tcp4, tcp6, unix
+func (s *server) Listen(network, address string) (*Listener, error) {
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewListener(ln), nil
+}
+
+// NewListener ...使用已有的 net.Listener
+func (s *server) NewListener(ln net.Listener) *Listener {
+	l := &Listener{s: s, ln: ln, conns: make(map[*rpcConn]struct{})}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	return l
+}
+
+// Addr ...
+func (l *Listener) Addr() net.Addr { return l.ln.Addr() }
+
+// Serve ...接受连接直到 Close, Close 之后返回nil
+func (l *Listener) Serve() error {
+	l.s.logger.Infof("[Listener] JSON-RPC listened on: %s", l.ln.Addr().String())
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if l.isClosed() || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		c := l.s.newConn(l.ctx, nil, newLineTransport(conn, l.s.opt.maxRequestContentLength()))
+		if !l.track(c, true) {
+			_ = conn.Close()
+			return nil
+		}
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			defer l.track(c, false)
+			c.serve()
+		}()
+	}
+}
+
+// Close ...停止接受新的连接, 已有的连接处理完正在执行的请求后关闭
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	conns := make([]*rpcConn, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+
+	err := l.ln.Close()
+	for _, c := range conns {
+		c.drain()
+	}
+	l.wg.Wait()
+	l.cancel()
+	return err
+}
+
+// AfterShutdown ...g2util.ItfGracefulProcess
+func (l *Listener) AfterShutdown() {
+	if err := l.Close(); err != nil {
+		l.s.logger.Errorf("[Listener] close %s: %s", l.ln.Addr().String(), err.Error())
+		return
+	}
+	l.s.logger.Infof("[Listener] Shutdown: %s exited", l.ln.Addr().String())
+}
+
+// isClosed ...
+func (l *Listener) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+// track ...添加或移除连接, 已经关闭时添加失败
+func (l *Listener) track(c *rpcConn, add bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !add {
+		delete(l.conns, c)
+		return true
+	}
+	if l.closed {
+		return false
+	}
+	l.conns[c] = struct{}{}
+	return true
+}
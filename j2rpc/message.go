@@ -2,6 +2,7 @@ package j2rpc
 
 import (
 	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/atcharles/gof/v2/json"
@@ -16,23 +17,108 @@ type RPCMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *Error          `json:"error,omitempty"`
+
+	//原始字段的编码, 为nil时为 JSONCodec
+	codec Codec
+	//流式响应的结果, 写出时才编码, 见 Option.AddStream
+	result interface{}
+	//请求所在的 http 请求
+	request *http.Request
+	//Error 的原始错误, 用于 Option.ErrorMappings
+	err error
+}
+
+// streamMessage ...流式响应, 信封与结果一次编码到 http.ResponseWriter
+type streamMessage struct {
+	ID      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+}
+
+// Codec ...
+func (r *RPCMessage) Codec() Codec {
+	if r.codec == nil {
+		return JSONCodec
+	}
+	return r.codec
+}
+
+// HTTPRequest ...请求所在的 http 请求, 用于中间件读取请求头; websocket 中为升级请求, ServeConn 中为nil
+func (r *RPCMessage) HTTPRequest() *http.Request { return r.request }
+
+func (r *RPCMessage) hasValidID() bool {
+	kind := r.Codec().Kind(r.ID)
+	return len(r.ID) > 0 && kind != reflect.Slice && kind != reflect.Map
+}
+
+// isNotification ...a message without id is a notification
+func (r *RPCMessage) isNotification() bool { return len(r.ID) == 0 }
+
+// NewResponse ...根据请求创建响应, 使用请求的编码; err 不为空时为错误响应; 零值结果不输出
+func NewResponse(req *RPCMessage, result interface{}, err error) *RPCMessage {
+	resp := &RPCMessage{ID: req.ID, Method: req.Method, codec: req.codec}
+	if err != nil {
+		return resp.setError(err)
+	}
+	if raw, ok := result.(json.RawMessage); ok {
+		resp.Result = raw
+		return resp
+	}
+	val := reflect.ValueOf(result)
+	if !val.IsValid() || val.IsZero() {
+		return resp
+	}
+	answer, err := resp.Codec().Marshal(result)
+	if err != nil {
+		return resp.setError(err)
+	}
+	resp.Result = answer
+	return resp
 }
 
-func (r *RPCMessage) hasValidID() bool { return len(r.ID) > 0 && r.ID[0] != '{' && r.ID[0] != '[' }
+// newStreamResponse ...结果不在此时编码, 由 Handler 直接写出; 零值结果与 NewResponse 相同不输出
+func newStreamResponse(req *RPCMessage, result interface{}) *RPCMessage {
+	val := reflect.ValueOf(result)
+	if _, ok := result.(json.RawMessage); ok || !val.IsValid() || val.IsZero() {
+		return NewResponse(req, result, nil)
+	}
+	return &RPCMessage{ID: req.ID, Method: req.Method, codec: req.codec, result: result}
+}
 
-// namespace ...returns the service's name
+// encodeResult ...将流式响应的结果编码到 Result, 用于批量请求与 websocket 等需要完整消息的场景
+func (r *RPCMessage) encodeResult() *RPCMessage {
+	if r.result == nil {
+		return r
+	}
+	result := r.result
+	r.result = nil
+	answer, err := r.Codec().Marshal(result)
+	if err != nil {
+		return r.setError(err)
+	}
+	r.Result = answer
+	return r
+}
+
+// errorResponse ...无法解析请求时的错误响应, id 为 null
+func errorResponse(c Codec, err error) *RPCMessage {
+	return (&RPCMessage{codec: c}).setError(err).output()
+}
+
+// namespace ...returns the service's name and the method name, the service's name may contain a version (v2.user.get)
 func (r *RPCMessage) methods() ([]string, error) {
-	elem := strings.SplitN(r.Method, splitMethodSeparator, 2)
-	if len(elem) != 2 {
+	i := strings.LastIndex(r.Method, splitMethodSeparator)
+	if i <= 0 || i == len(r.Method)-1 {
 		return nil, NewError(ErrNoMethod, "wrong method")
 	}
-	return elem, nil
+	return []string{r.Method[:i], r.Method[i+1:]}, nil
 }
 
 // output ...
 func (r *RPCMessage) output() *RPCMessage {
+	//无法确定请求id时, 按照规范返回 null
 	if len(r.ID) == 0 {
-		r.ID = []byte{'1'}
+		r.ID, _ = r.Codec().Marshal(nil)
 	}
 	r.Version = vsn
 	r.Method = ""
@@ -57,22 +143,10 @@ func (r *RPCMessage) setError(err error) *RPCMessage {
 		e = NewError(ErrServer, _e.Error())
 	}
 	r.Error = e
+	r.err = err
+	r.result = nil
 	return r
 }
 
 // writeResponse ...
-func (r *RPCMessage) writeResponse(w http.ResponseWriter) {
-	if len(w.Header().Get("Status-Written")) != 0 {
-		return
-	}
-
-	bts, err := json.Marshal(r)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	AbortWriteHeader(w, http.StatusOK)
-	n, err := w.Write(bts)
-	_, _ = n, err
-}
+func (r *RPCMessage) writeResponse(w http.ResponseWriter) { writeCodec(w, r.Codec(), r) }
@@ -0,0 +1,183 @@
+package j2rpc
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unknownMethod ...不存在的方法统一记录为 unknown, 避免任意的方法名导致指标无限增长
+const unknownMethod = "unknown"
+
+// defaultMetricsBuckets ...与 Prometheus 客户端默认的桶相同, 单位为秒
+var defaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type (
+	//metrics ...按方法统计的请求数, 错误数与耗时分布
+	metrics struct {
+		mu      sync.RWMutex
+		buckets []float64
+		methods map[string]*methodMetrics
+	}
+	//methodMetrics ...
+	methodMetrics struct {
+		mu     sync.Mutex
+		count  uint64
+		sum    float64
+		counts []uint64
+		errors map[ErrorCode]uint64
+	}
+)
+
+// newMetrics ...
+func newMetrics(buckets []float64) *metrics {
+	if len(buckets) == 0 {
+		buckets = defaultMetricsBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &metrics{buckets: buckets, methods: make(map[string]*methodMetrics)}
+}
+
+// method ...
+func (m *metrics) method(name string) *methodMetrics {
+	m.mu.RLock()
+	mm, ok := m.methods[name]
+	m.mu.RUnlock()
+	if ok {
+		return mm
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mm, ok = m.methods[name]; !ok {
+		mm = &methodMetrics{counts: make([]uint64, len(m.buckets)), errors: make(map[ErrorCode]uint64)}
+		m.methods[name] = mm
+	}
+	return mm
+}
+
+// observe ...记录一次请求, resp 为nil或没有错误时为成功
+func (m *metrics) observe(method string, d time.Duration, resp *RPCMessage) {
+	mm := m.method(method)
+	seconds := d.Seconds()
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.count++
+	mm.sum += seconds
+	for i, le := range m.buckets {
+		if seconds <= le {
+			mm.counts[i]++
+		}
+	}
+	if resp != nil && resp.Error != nil {
+		mm.errors[resp.Error.Code]++
+	}
+}
+
+// write ...输出 Prometheus 文本格式, https://prometheus.io/docs/instrumenting/exposition_formats/
+func (m *metrics) write(w io.Writer) error {
+	m.mu.RLock()
+	names := make([]string, 0, len(m.methods))
+	for name := range m.methods {
+		names = append(names, name)
+	}
+	m.mu.RUnlock()
+	sort.Strings(names)
+
+	type snapshot struct {
+		name   string
+		count  uint64
+		sum    float64
+		counts []uint64
+		codes  []int
+		errors map[ErrorCode]uint64
+	}
+	list := make([]snapshot, len(names))
+	for i, name := range names {
+		mm := m.method(name)
+		mm.mu.Lock()
+		sp := snapshot{name: name, count: mm.count, sum: mm.sum, counts: append([]uint64(nil), mm.counts...),
+			errors: make(map[ErrorCode]uint64, len(mm.errors))}
+		for code, n := range mm.errors {
+			sp.errors[code] = n
+			sp.codes = append(sp.codes, int(code))
+		}
+		mm.mu.Unlock()
+		sort.Ints(sp.codes)
+		list[i] = sp
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# HELP j2rpc_requests_total Total number of JSON-RPC requests.\n")
+	bw.WriteString("# TYPE j2rpc_requests_total counter\n")
+	for _, sp := range list {
+		bw.WriteString("j2rpc_requests_total{method=\"" + metricsLabel(sp.name) + "\"} ")
+		bw.WriteString(strconv.FormatUint(sp.count, 10) + "\n")
+	}
+	bw.WriteString("# HELP j2rpc_errors_total Total number of JSON-RPC error responses by error code.\n")
+	bw.WriteString("# TYPE j2rpc_errors_total counter\n")
+	for _, sp := range list {
+		for _, code := range sp.codes {
+			bw.WriteString("j2rpc_errors_total{method=\"" + metricsLabel(sp.name) + "\",code=\"" +
+				strconv.Itoa(code) + "\"} ")
+			bw.WriteString(strconv.FormatUint(sp.errors[ErrorCode(code)], 10) + "\n")
+		}
+	}
+	bw.WriteString("# HELP j2rpc_request_duration_seconds JSON-RPC request latency in seconds.\n")
+	bw.WriteString("# TYPE j2rpc_request_duration_seconds histogram\n")
+	for _, sp := range list {
+		label := "method=\"" + metricsLabel(sp.name) + "\""
+		for i, le := range m.buckets {
+			bw.WriteString("j2rpc_request_duration_seconds_bucket{" + label + ",le=\"" +
+				strconv.FormatFloat(le, 'g', -1, 64) + "\"} ")
+			bw.WriteString(strconv.FormatUint(sp.counts[i], 10) + "\n")
+		}
+		bw.WriteString("j2rpc_request_duration_seconds_bucket{" + label + ",le=\"+Inf\"} ")
+		bw.WriteString(strconv.FormatUint(sp.count, 10) + "\n")
+		bw.WriteString("j2rpc_request_duration_seconds_sum{" + label + "} ")
+		bw.WriteString(strconv.FormatFloat(sp.sum, 'g', -1, 64) + "\n")
+		bw.WriteString("j2rpc_request_duration_seconds_count{" + label + "} ")
+		bw.WriteString(strconv.FormatUint(sp.count, 10) + "\n")
+	}
+	return bw.Flush()
+}
+
+// metricsLabel ...转义标签的值
+func metricsLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// WriteMetrics ...输出 Prometheus 文本格式的指标, Option.DisableMetrics 时为空
+func (s *server) WriteMetrics(w io.Writer) error {
+	if s.metrics == nil {
+		return nil
+	}
+	return s.metrics.write(w)
+}
+
+// ServeMetrics ...以 http GET 的方式输出 Prometheus 文本格式的指标
+func (s *server) ServeMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := s.WriteMetrics(w); err != nil {
+		s.logger.Errorf("[Metrics] %s", err.Error())
+	}
+}
+
+// observe ...elem 为nil或方法不存在时记录为 unknown
+func (s *server) observe(elem []string, start time.Time, resp *RPCMessage) {
+	if s.metrics == nil {
+		return
+	}
+	method := unknownMethod
+	if len(elem) == 2 {
+		if _, err := s.getCallBack(elem); err == nil {
+			method = strings.Join(elem, splitMethodSeparator)
+		}
+	}
+	s.metrics.observe(method, time.Since(start), resp)
+}
@@ -0,0 +1,63 @@
+package j2rpc
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestServeMetrics ...请求数, 按错误码的错误数与耗时分布, 不存在的方法记录为 unknown
+func TestServeMetrics(t *testing.T) {
+	s := New(&Option{SnakeNamespace: true, MetricsBuckets: []float64{60, 1}})
+	s.Logger().SetOutput(new(bytes.Buffer))
+	s.Register(new(testAPI), "test")
+	postRPC(s, `{"id":1,"method":"test.echo","params":["a"]}`)
+	postRPC(s, `{"id":2,"method":"test.echo","params":["b"]}`)
+	postRPC(s, `{"id":3,"method":"test.fail"}`)
+	postRPC(s, `{"id":4,"method":"test.none"}`)
+
+	w := httptest.NewRecorder()
+	s.ServeMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE j2rpc_requests_total counter",
+		`j2rpc_requests_total{method="test.echo"} 2`,
+		`j2rpc_requests_total{method="test.fail"} 1`,
+		`j2rpc_requests_total{method="unknown"} 1`,
+		"# TYPE j2rpc_errors_total counter",
+		`j2rpc_errors_total{method="test.fail",code="-32000"} 1`,
+		`j2rpc_errors_total{method="unknown",code="-32601"} 1`,
+		"# TYPE j2rpc_request_duration_seconds histogram",
+		`j2rpc_request_duration_seconds_bucket{method="test.echo",le="1"} 2`,
+		`j2rpc_request_duration_seconds_bucket{method="test.echo",le="60"} 2`,
+		`j2rpc_request_duration_seconds_bucket{method="test.echo",le="+Inf"} 2`,
+		`j2rpc_request_duration_seconds_count{method="test.fail"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `j2rpc_errors_total{method="test.echo"`) {
+		t.Errorf("successful calls counted as errors:\n%s", body)
+	}
+	if strings.Index(body, `le="1"`) > strings.Index(body, `le="60"`) {
+		t.Errorf("buckets not sorted:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	newTestServer(t, nil).ServeMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Body.Len() > 0 {
+		t.Errorf("DisableMetrics: %q", w.Body.String())
+	}
+}
+
+func TestMetricsLabel(t *testing.T) {
+	if got := metricsLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("metricsLabel = %q", got)
+	}
+}
@@ -1,17 +1,25 @@
 package j2rpc
 
 import (
+	"context"
 	"regexp"
 	"strings"
 )
 
+type (
+	//Handler ...处理请求, 返回响应
+	Handler func(ctx context.Context, req *RPCMessage) *RPCMessage
+	//Middleware ...环绕型中间件, 调用 next 继续执行后续中间件及方法
+	Middleware func(ctx context.Context, req *RPCMessage, next Handler) *RPCMessage
+)
+
 // middleInfo ...中间件信息
 type middleInfo struct {
 	//作用于方法名, 支持正则表达式
 	method []string
 	//排除的方法
 	excludes []string
-	//处理函数:参数顺序: ctx,method,writer,request
+	//处理函数:参数顺序: ctx,method,writer,request; 或者 Middleware
 	function interface{}
 	//所有均经过
 	all bool
@@ -0,0 +1,166 @@
+package j2rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	openRPCVersion = "1.2.6"
+
+	rpcNamespace = "rpc"
+)
+
+type (
+	//OpenRPCDocument ...https://spec.open-rpc.org
+	OpenRPCDocument struct {
+		OpenRPC    string             `json:"openrpc"`
+		Info       *OpenRPCInfo       `json:"info"`
+		Methods    []*OpenRPCMethod   `json:"methods"`
+		Components *OpenRPCComponents `json:"components,omitempty"`
+	}
+	//OpenRPCInfo ...
+	OpenRPCInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+	//OpenRPCMethod ...
+	OpenRPCMethod struct {
+		Name           string                      `json:"name"`
+		ParamStructure string                      `json:"paramStructure,omitempty"`
+		Params         []*OpenRPCContentDescriptor `json:"params"`
+		Result         *OpenRPCContentDescriptor   `json:"result"`
+		Deprecated     bool                        `json:"deprecated,omitempty"`
+		//方法的访问规则, 公开的方法为空
+		Access *AccessRule `json:"x-access,omitempty"`
+	}
+	//OpenRPCContentDescriptor ...
+	OpenRPCContentDescriptor struct {
+		Name     string  `json:"name"`
+		Required bool    `json:"required,omitempty"`
+		Schema   *Schema `json:"schema"`
+	}
+	//OpenRPCComponents ...
+	OpenRPCComponents struct {
+		Schemas map[string]*Schema `json:"schemas,omitempty"`
+	}
+
+	//rpcService ...内置的 rpc 命名空间
+	rpcService struct{ s *server }
+)
+
+// Discover ...rpc.discover
+func (r *rpcService) Discover() *OpenRPCDocument { return r.s.Discover() }
+
+// Unsubscribe ...rpc.unsubscribe, 结束当前连接中的订阅, 订阅不存在时返回false
+func (r *rpcService) Unsubscribe(ctx context.Context, id string) (bool, error) {
+	f := frameFromContext(ctx)
+	if f == nil {
+		return false, NewError(ErrInvalidRequest, "subscription requires websocket or ServeConn")
+	}
+	sub := f.conn.subscription(id)
+	if sub == nil {
+		return false, nil
+	}
+	sub.Unsubscribe()
+	return true, nil
+}
+
+// ExcludeMethod ...
+func (r *rpcService) ExcludeMethod() []string {
+	if r.s.opt.DisableDiscover {
+		return []string{"Discover"}
+	}
+	return nil
+}
+
+// Discover ...根据已注册的服务生成 OpenRPC 文档
+func (s *server) Discover() *OpenRPCDocument {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b := newSchemaBuilder()
+	doc := &OpenRPCDocument{
+		OpenRPC:    openRPCVersion,
+		Info:       &OpenRPCInfo{Title: "j2rpc", Version: "1.0.0"},
+		Methods:    make([]*OpenRPCMethod, 0),
+		Components: &OpenRPCComponents{Schemas: b.definitions},
+	}
+	now := time.Now()
+	for _, srv := range s.sortedServices() {
+		if _, ok := srv.receiver.Interface().(*rpcService); ok {
+			continue
+		}
+		for _, name := range srv.sortedMethods() {
+			cbk := srv.callbacks[name]
+			//已经移除的方法不再输出
+			d := s.deprecation(srv.name, cbk)
+			if d != nil && d.removed(now) {
+				continue
+			}
+			m := cbk.openRPCMethod(b, srv.name+splitMethodSeparator+name)
+			m.Deprecated = d != nil && d.active(now)
+			doc.Methods = append(doc.Methods, m)
+		}
+	}
+	return doc
+}
+
+// ServeDiscover ...以 http GET 的方式输出 OpenRPC 文档
+func (s *server) ServeDiscover(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.Discover())
+}
+
+// sortedServices ...按名称排序, 保证生成的文档稳定
+func (s *server) sortedServices() []service {
+	list := make([]service, 0, len(s.services))
+	for _, srv := range s.services {
+		list = append(list, srv)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// sortedMethods ...
+func (s service) sortedMethods() []string {
+	list := make([]string, 0, len(s.callbacks))
+	for name := range s.callbacks {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// registerRPCService ...
+func (s *server) registerRPCService() { s.Register(&rpcService{s: s}, rpcNamespace) }
+
+// openRPCMethod ...
+func (c *callback) openRPCMethod(b *schemaBuilder, name string) *OpenRPCMethod {
+	m := &OpenRPCMethod{Name: name, ParamStructure: "by-position", Params: make([]*OpenRPCContentDescriptor, 0)}
+	if len(c.argNames) > 0 || (len(c.argTypes) == 1 && isObjectType(c.argTypes[0])) {
+		m.ParamStructure = "either"
+	}
+	for i, argType := range c.argTypes {
+		argName := fmt.Sprintf("arg%d", i)
+		if len(c.argNames) > 0 {
+			argName = c.argNames[i]
+		}
+		m.Params = append(m.Params, &OpenRPCContentDescriptor{
+			Name:     argName,
+			Required: argType.Kind() != reflect.Ptr,
+			Schema:   b.build(argType),
+		})
+	}
+	if c.access.requireAuth() {
+		m.Access = c.access
+	}
+	m.Result = &OpenRPCContentDescriptor{Name: "result", Schema: &Schema{Type: "null"}}
+	if rt := c.resultType(); rt != nil {
+		m.Result.Schema = b.build(rt)
+	}
+	return m
+}
@@ -0,0 +1,126 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+type discoverItem struct {
+	Name string `json:"name" validate:"required"`
+	Note string `json:"note,omitempty"`
+}
+
+type discoverAPI struct{}
+
+func (*discoverAPI) Create(ctx context.Context, item discoverItem, dryRun *bool) (int64, error) {
+	return 1, nil
+}
+
+func (*discoverAPI) List(page int) ([]*discoverItem, error) { return nil, nil }
+
+func (*discoverAPI) Remove(id int64) error { return nil }
+
+func (*discoverAPI) J2rpcParamNames() map[string][]string {
+	return map[string][]string{"Create": {"item", "dry_run"}}
+}
+
+// schemaJSON ...
+func schemaJSON(t *testing.T, sc *Schema) string {
+	t.Helper()
+	bts, err := json.Marshal(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bts)
+}
+
+func TestDiscover(t *testing.T) {
+	s := newTestServer(t, nil, new(discoverAPI))
+	doc := s.Discover()
+	methods := make(map[string]*OpenRPCMethod)
+	var names []string
+	for _, m := range doc.Methods {
+		names = append(names, m.Name)
+		methods[m.Name] = m
+	}
+	if want := []string{"test.create", "test.list", "test.remove"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("methods = %v, want %v (rpc.* excluded)", names, want)
+	}
+
+	type param struct {
+		Name     string
+		Required bool
+		Schema   string
+	}
+	tests := []struct {
+		method    string
+		structure string
+		params    []param
+		result    string
+	}{
+		{"test.create", "either", []param{
+			{"item", true, `{"$ref":"#/components/schemas/discoverItem"}`},
+			{"dry_run", false, `{"type":"boolean"}`},
+		}, `{"type":"integer"}`},
+		{"test.list", "by-position", []param{{"arg0", true, `{"type":"integer"}`}},
+			`{"type":"array","items":{"$ref":"#/components/schemas/discoverItem"}}`},
+		{"test.remove", "by-position", []param{{"arg0", true, `{"type":"integer"}`}}, `{"type":"null"}`},
+	}
+	for _, tt := range tests {
+		m := methods[tt.method]
+		if m.ParamStructure != tt.structure {
+			t.Errorf("%s: paramStructure %q, want %q", tt.method, m.ParamStructure, tt.structure)
+		}
+		var params []param
+		for _, p := range m.Params {
+			params = append(params, param{p.Name, p.Required, schemaJSON(t, p.Schema)})
+		}
+		if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%s: params %+v, want %+v", tt.method, params, tt.params)
+		}
+		if got := schemaJSON(t, m.Result.Schema); m.Result.Name != "result" || got != tt.result {
+			t.Errorf("%s: result %s, want %s", tt.method, got, tt.result)
+		}
+	}
+
+	item := doc.Components.Schemas["discoverItem"]
+	if item == nil {
+		t.Fatalf("components: %v", doc.Components.Schemas)
+	}
+	want := `{"type":"object","properties":{"name":{"type":"string","x-validate":"required"},` +
+		`"note":{"type":"string"}},"required":["name"]}`
+	if got := schemaJSON(t, item); got != want {
+		t.Errorf("discoverItem schema = %s, want %s", got, want)
+	}
+}
+
+// TestServeDiscover ...http GET 与 rpc.discover 输出相同的文档, DisableDiscover 时不注册 rpc.discover
+func TestServeDiscover(t *testing.T) {
+	s := newTestServer(t, nil, new(discoverAPI))
+	w := httptest.NewRecorder()
+	s.ServeDiscover(w, httptest.NewRequest(http.MethodGet, "/jsonrpc/discover", nil))
+	want, _ := json.Marshal(s.Discover())
+	var got, viaRPC interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("ServeDiscover %q: %v", w.Body.String(), err)
+	}
+	resp := decodeResponse(t, postRPC(s, `{"id":1,"method":"rpc.discover"}`))
+	if err := json.Unmarshal(resp.Result, &viaRPC); err != nil {
+		t.Fatalf("rpc.discover %s: %v", resp.Result, resp.Error)
+	}
+	var doc interface{}
+	_ = json.Unmarshal(want, &doc)
+	if !reflect.DeepEqual(got, doc) || !reflect.DeepEqual(viaRPC, doc) {
+		t.Errorf("ServeDiscover and rpc.discover differ from Discover:\n%s\n%s", w.Body.String(), resp.Result)
+	}
+
+	s = newTestServer(t, &Option{SnakeNamespace: true, DisableDiscover: true}, new(discoverAPI))
+	if resp = decodeResponse(t, postRPC(s, `{"id":1,"method":"rpc.discover"}`)); resp.errorCode() != ErrNoMethod {
+		t.Errorf("DisableDiscover: %s %v", resp.Result, resp.Error)
+	}
+}
@@ -1,7 +1,14 @@
 package j2rpc
 
 import (
+	"context"
+	"io"
+	"net/http"
 	"reflect"
+	"sync"
+	"time"
+
+	"github.com/atcharles/gof/v2/g2cache/store"
 )
 
 // SnakeOption ...
@@ -11,6 +18,49 @@ var SnakeOption = &Option{SnakeNamespace: true}
 type Option struct {
 	SnakeNamespace bool
 	BeforeMid      []middleInfo
+	Mid            []middleInfo
+	//批量请求的最大并发数, 小于等于1时按顺序执行
+	BatchConcurrency int
+	//禁用内置的 rpc.discover 方法
+	DisableDiscover bool
+	//方法默认的超时时间, 为0时不限制; 只限制方法本身, 参数的解析与校验在请求的 goroutine 中执行
+	Timeout time.Duration
+	//按方法匹配的超时时间, 优先于 Timeout
+	Timeouts []middleInfo
+	//websocket 升级时检查 Origin, 为nil时允许所有来源
+	WebsocketCheckOrigin func(r *http.Request) bool
+	//校验声明了访问规则(AccessRule)的方法的请求身份
+	Authenticator Authenticator
+	//额外的编码, 按 Content-Type 匹配, 优先于内置的 JSON, MessagePack, CBOR
+	Codecs []Codec
+	//请求体的最大长度, 为0时为 5MB
+	MaxRequestContentLength int64
+	//响应压缩(br, gzip)的最小长度, 为0时为 1KB, 小于0时不压缩; 流式响应总是压缩
+	CompressMinLength int
+	//流式响应的方法, 结果直接编码到 http.ResponseWriter
+	Streams []middleInfo
+	//指定版本的请求头, 为空时为 X-Api-Version
+	VersionHeader string
+	//废弃的版本, key 为版本如 v1, 见 DeprecateVersion
+	DeprecatedVersions map[string]Deprecation
+	//不统计方法的请求数, 错误数与耗时
+	DisableMetrics bool
+	//耗时分布的桶(秒), 为空时与 Prometheus 客户端默认的桶相同
+	MetricsBuckets []float64
+	//保存 ItfCacheable 声明的方法的结果, 为nil时不缓存
+	Cache store.ItfCache
+	//CacheRule.PerUser 时请求的用户, 如 g2db 的 token.J2rpcUser()
+	CacheUser func(ctx context.Context) string
+	//方法返回的错误的转换, 按注册顺序匹配第一个, 见 MapError, MapErrorType
+	ErrorMappings []errorMapping
+	//生产模式: 没有转换的错误只返回 ErrServer 与通用的信息, 原始错误与请求id记录在日志中
+	Production bool
+	//审计日志, 每个请求写入一行 JSON, 如 AbFile.MustLogIO("rpc_audit"); 为nil时不记录, 需要支持并发写入
+	Audit io.Writer
+	//审计日志中请求的用户, 如 g2db 的 token.J2rpcUser()
+	AuditUser func(ctx context.Context) string
+	//审计日志中参数的脱敏规则, 见 AddAuditRedact
+	AuditRedacts []middleInfo
 }
 
 //AddBeforeMiddleware ...
@@ -21,35 +71,121 @@ type Option struct {
  * @param fn: //参数顺序: ctx,method,writer,request
  */
 func (o *Option) AddBeforeMiddleware(fn interface{}, ls ...[]string) {
+	o.BeforeMid = append(o.BeforeMid, newMiddleInfo(fn, ls...))
+}
+
+// AddMiddleware ...添加环绕型中间件, 按添加顺序由外到内执行; 方法匹配规则与 AddBeforeMiddleware 相同
+func (o *Option) AddMiddleware(fn Middleware, ls ...[]string) {
+	o.Mid = append(o.Mid, newMiddleInfo(fn, ls...))
+}
+
+// AddTimeout ...为匹配的方法设置超时时间, 按添加顺序匹配第一个; 方法匹配规则与 AddBeforeMiddleware 相同
+func (o *Option) AddTimeout(d time.Duration, ls ...[]string) {
+	o.Timeouts = append(o.Timeouts, newMiddleInfo(d, ls...))
+}
+
+// AddStream ...匹配的方法使用流式响应: 结果不再先编码到 RPCMessage.Result, 而是与响应信封一起直接编码到
+// http.ResponseWriter, 适用于返回大数组的方法; 方法匹配规则与 AddBeforeMiddleware 相同
+// 注意: 中间件中无法读取流式响应的 Result, 批量请求与 websocket 中仍然先编码
+func (o *Option) AddStream(ls ...[]string) {
+	o.Streams = append(o.Streams, newMiddleInfo(true, ls...))
+}
+
+// MapError ...errors.Is(err, target) 的错误转换为 m
+/**
+opt.MapError(sql.ErrNoRows, j2rpc.ErrorMapping{Code: j2rpc.ErrNotFound, Message: "数据不存在"})
+*/
+func (o *Option) MapError(target error, m ErrorMapping) {
+	if target == nil {
+		panic("j2rpc: MapError target is nil")
+	}
+	o.ErrorMappings = append(o.ErrorMappings, errorMapping{ErrorMapping: m, target: target})
+}
+
+// MapErrorType ...errors.As(err, target) 的错误转换为 m, target 为指向错误类型的指针, 与 errors.As 相同
+/**
+opt.MapErrorType(new(g2db.ErrorMysqlNotFound), j2rpc.ErrorMapping{Code: j2rpc.ErrNotFound, Message: "数据不存在"})
+opt.MapErrorType(new(*mysql.MySQLError), j2rpc.ErrorMapping{Code: j2rpc.ErrServer, Message: "数据库错误"})
+*/
+func (o *Option) MapErrorType(target interface{}, m ErrorMapping) {
+	t := reflect.TypeOf(target)
+	if t == nil || t.Kind() != reflect.Ptr ||
+		(t.Elem().Kind() != reflect.Interface && !t.Elem().Implements(errorType)) {
+		panic("j2rpc: MapErrorType target must be a pointer to an interface or to a type implementing error")
+	}
+	o.ErrorMappings = append(o.ErrorMappings, errorMapping{ErrorMapping: m, typ: t.Elem()})
+}
+
+// stream ...
+func (o *Option) stream(method string) bool {
+	for _, info := range o.Streams {
+		if info.getMatchFunction(method) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// maxRequestContentLength ...
+func (o *Option) maxRequestContentLength() int64 {
+	if o.MaxRequestContentLength > 0 {
+		return o.MaxRequestContentLength
+	}
+	return defaultMaxRequestContentLength
+}
+
+// compressMinLength ...
+func (o *Option) compressMinLength() int {
+	if o.CompressMinLength == 0 {
+		return defaultCompressMinLength
+	}
+	return o.CompressMinLength
+}
+
+// batchEach ...对批量请求的每个元素执行fn, 并发数受 BatchConcurrency 限制
+func (o *Option) batchEach(n int, fn func(i int)) {
+	if o.BatchConcurrency <= 1 || n == 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
 	var (
-		all      bool
-		method   []string
-		excludes []string
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, o.BatchConcurrency)
 	)
-	switch len(ls) {
-	case 0:
-		all = true
-	case 1:
-		method = ls[0]
-	default:
-		method = ls[0]
-		if len(ls) > 2 {
-			//第一个参数是包含的路径
-			//第二个参数是排除的路径
-			//从第三个参数开始,后面的全部都是排除的路径
-			for i := 2; i < len(ls); i++ {
-				ls[1] = append(ls[1], ls[i]...)
-			}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// chain ...组装匹配 method 的中间件链, h 为最内层的处理函数
+func (o *Option) chain(method string, h Handler) Handler {
+	for i := len(o.Mid) - 1; i >= 0; i-- {
+		fn, ok := o.Mid[i].getMatchFunction(method).(Middleware)
+		if !ok {
+			continue
 		}
-		excludes = ls[1]
+		next := h
+		h = func(ctx context.Context, req *RPCMessage) *RPCMessage { return fn(ctx, req, next) }
 	}
-	info := middleInfo{
-		method:   method,
-		excludes: excludes,
-		function: fn,
-		all:      all,
+	return h
+}
+
+// timeout ...
+func (o *Option) timeout(method string) time.Duration {
+	for _, info := range o.Timeouts {
+		if d, ok := info.getMatchFunction(method).(time.Duration); ok {
+			return d
+		}
 	}
-	o.BeforeMid = append(o.BeforeMid, info)
+	return o.Timeout
 }
 
 // beforeMiddlewareAction ...
@@ -111,3 +247,35 @@ func (o *Option) beforeMiddlewareFuncAction(fn interface{}, args ...interface{})
 	}
 	return
 }
+
+// newMiddleInfo ...
+func newMiddleInfo(fn interface{}, ls ...[]string) middleInfo {
+	var (
+		all      bool
+		method   []string
+		excludes []string
+	)
+	switch len(ls) {
+	case 0:
+		all = true
+	case 1:
+		method = ls[0]
+	default:
+		method = ls[0]
+		if len(ls) > 2 {
+			//第一个参数是包含的路径
+			//第二个参数是排除的路径
+			//从第三个参数开始,后面的全部都是排除的路径
+			for i := 2; i < len(ls); i++ {
+				ls[1] = append(ls[1], ls[i]...)
+			}
+		}
+		excludes = ls[1]
+	}
+	return middleInfo{
+		method:   method,
+		excludes: excludes,
+		function: fn,
+		all:      all,
+	}
+}
@@ -0,0 +1,74 @@
+package j2rpc
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type (
+	namedAPI struct{}
+
+	namedArgs struct {
+		Name  string `json:"name" validate:"required"`
+		Age   int    `json:"age"`
+		Email string `json:"email,omitempty" validate:"omitempty,email"`
+	}
+)
+
+func (*namedAPI) Sum(a int, b *int) int {
+	if b == nil {
+		return a
+	}
+	return a + *b
+}
+
+func (*namedAPI) Create(args namedArgs) string { return args.Name }
+
+func (*namedAPI) J2rpcParamNames() map[string][]string {
+	return map[string][]string{"Sum": {"a", "b"}}
+}
+
+func TestNamedParams(t *testing.T) {
+	s := newTestServer(t, nil, new(namedAPI))
+	tests := []struct {
+		body   string
+		result string
+		code   ErrorCode
+	}{
+		{`{"id":1,"method":"test.sum","params":{"a":1,"b":2}}`, `3`, 0},
+		{`{"id":1,"method":"test.sum","params":{"a":1}}`, `1`, 0},
+		{`{"id":1,"method":"test.sum","params":{"b":2}}`, ``, ErrBadParams},
+		{`{"id":1,"method":"test.sum","params":{"a":1,"c":2}}`, ``, ErrBadParams},
+		{`{"id":1,"method":"test.sum","params":[1,2]}`, `3`, 0},
+		{`{"id":1,"method":"test.create","params":{"name":"n","age":1}}`, `"n"`, 0},
+		{`{"id":1,"method":"test.create","params":{"NAME":"n"}}`, `"n"`, 0},
+		{`{"id":1,"method":"test.create","params":{"age":1}}`, ``, ErrBadParams},
+		{`{"id":1,"method":"test.create","params":{"name":null}}`, ``, ErrBadParams},
+		{`{"id":1,"method":"test.create","params":{"name":"n","other":1}}`, ``, ErrBadParams},
+	}
+	for _, tt := range tests {
+		resp := decodeResponse(t, postRPC(s, tt.body))
+		if resp.errorCode() != tt.code || string(resp.Result) != tt.result {
+			t.Errorf("%s: result=%s error=%+v, want result=%s code=%d", tt.body, resp.Result, resp.Error,
+				tt.result, tt.code)
+		}
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	type embedded struct {
+		ID int `json:"id" validate:"required"`
+	}
+	type args struct {
+		embedded
+		Name  string   `validate:"required,min=1"`
+		Tags  []string `json:"tags" validate:"dive,required"`
+		Skip  string   `json:"-" validate:"required"`
+		inner string
+	}
+	got := requiredFields(reflect.TypeOf(&args{}))
+	if want := []string{"id", "Name"}; !slices.Equal(got, want) {
+		t.Errorf("requiredFields = %v, want %v", got, want)
+	}
+}
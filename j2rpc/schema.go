@@ -0,0 +1,271 @@
+package j2rpc
+
+import (
+	"encoding"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atcharles/gof/v2/g2util"
+	"github.com/atcharles/gof/v2/json"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*interface{ MarshalJSON() ([]byte, error) })(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	schemaNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+// Schema ...JSON Schema, 由 go 类型通过反射生成
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	//原始的 validate 标签
+	Validate string `json:"x-validate,omitempty"`
+}
+
+// schemaBuilder ...命名的结构体类型放入 definitions, 以 $ref 引用
+type schemaBuilder struct {
+	definitions map[string]*Schema
+	names       map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{definitions: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// build ...
+func (b *schemaBuilder) build(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == rawMessageType:
+		return &Schema{}
+	case t == timeType || (t.Kind() == reflect.Struct && t.ConvertibleTo(timeType)):
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.build(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.build(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return b.structSchema(t)
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = b.definitionName(t)
+			b.names[t] = name
+			//先占位, 防止递归类型无限循环
+			b.definitions[name] = &Schema{}
+			*b.definitions[name] = *b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// definitionName ...
+func (b *schemaBuilder) definitionName(t reflect.Type) string {
+	return uniqueTypeName(t, func(name string) bool { _, ok := b.definitions[name]; return ok })
+}
+
+// uniqueTypeName ...不同包中的同名类型, 使用包名区分
+func uniqueTypeName(t reflect.Type, exists func(name string) bool) string {
+	name := schemaNameReplacer.ReplaceAllString(t.Name(), "_")
+	if !exists(name) {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	name = schemaNameReplacer.ReplaceAllString(pkg, "_") + "_" + name
+	for i := 2; ; i++ {
+		if !exists(name) {
+			return name
+		}
+		name = strings.TrimRight(name, "0123456789") + strconv.Itoa(i)
+	}
+}
+
+// structSchema ...
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	sc := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.addFields(sc, t)
+	return sc
+}
+
+// addFields ...
+func (b *schemaBuilder) addFields(sc *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, skip := jsonFieldName(f)
+		if skip {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		//匿名嵌入且没有指定json名称的结构体, 字段提升到当前层级
+		if f.Anonymous && ft.Kind() == reflect.Struct && len(name) == 0 {
+			b.addFields(sc, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		fs := b.build(f.Type)
+		if tag := f.Tag.Get("validate"); len(tag) > 0 {
+			if applyValidateTag(fs, ft, tag) {
+				sc.Required = append(sc.Required, name)
+			}
+		}
+		sc.Properties[name] = fs
+	}
+}
+
+// jsonFieldName ...returns json name, omitempty and whether the field is ignored
+func jsonFieldName(f reflect.StructField) (name string, omitempty bool, skip bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return parts[0], omitempty, false
+}
+
+// applyValidateTag ...将 validate 标签转换为 schema 约束, 返回字段是否必须
+func applyValidateTag(sc *Schema, t reflect.Type, tag string) (required bool) {
+	var (
+		_float = func(s string) *float64 {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil
+			}
+			return &v
+		}
+		_int = func(s string) *int {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return nil
+			}
+			return &v
+		}
+	)
+	//ref 时约束写在引用上, 不影响定义本身
+	sc.Validate = tag
+	kind := t.Kind()
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			//dive 之后的规则作用于元素
+			return
+		case "required":
+			required = true
+		case "min", "max", "len":
+			switch kind {
+			case reflect.String:
+				if key != "max" {
+					sc.MinLength = _int(param)
+				}
+				if key != "min" {
+					sc.MaxLength = _int(param)
+				}
+			case reflect.Slice, reflect.Array, reflect.Map:
+				if key != "max" {
+					sc.MinItems = _int(param)
+				}
+				if key != "min" {
+					sc.MaxItems = _int(param)
+				}
+			default:
+				if key != "max" {
+					sc.Minimum = _float(param)
+				}
+				if key != "min" {
+					sc.Maximum = _float(param)
+				}
+			}
+		case "gte":
+			sc.Minimum = _float(param)
+		case "lte":
+			sc.Maximum = _float(param)
+		case "gt":
+			sc.ExclusiveMinimum = _float(param)
+		case "lt":
+			sc.ExclusiveMaximum = _float(param)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if f := _float(v); f != nil && kind != reflect.String {
+					sc.Enum = append(sc.Enum, *f)
+					continue
+				}
+				sc.Enum = append(sc.Enum, v)
+			}
+		case "email":
+			sc.Format = "email"
+		case "url", "uri":
+			sc.Format = "uri"
+		case "uuid":
+			sc.Format = "uuid"
+		case "ip":
+			sc.Format = "ipv4"
+		case "mobile":
+			sc.Pattern = g2util.PatternMobile
+		case "username":
+			sc.Pattern = g2util.PatternUsername
+		}
+	}
+	return
+}
@@ -0,0 +1,179 @@
+package j2rpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/atcharles/gof/v2/g2util"
+	"github.com/atcharles/gof/v2/json"
+)
+
+// subscriptionMethod ...订阅通知的方法名
+const subscriptionMethod = "rpc.subscription"
+
+var (
+	subscriptionType = reflect.TypeOf((*Subscription)(nil))
+	stringType       = reflect.TypeOf("")
+
+	//ErrSubscriptionClosed ...
+	ErrSubscriptionClosed = errors.New("subscription closed")
+)
+
+type (
+	//Subscription ...服务端推送的订阅, 仅在 websocket 与 ServeConn 的连接中可用
+	/**
+	方法返回 channel 或 *Subscription 时为订阅方法, 响应的结果为订阅id, 之后推送通知:
+	{"jsonrpc":"2.0","method":"rpc.subscription","params":{"subscription":"id","result":{}}}
+	调用 rpc.unsubscribe(id) 或连接断开时, 订阅结束, 方法的 ctx 被取消
+
+	func (o *Order) Watch(ctx context.Context, orderID int64) (*j2rpc.Subscription, error) {
+		sub := j2rpc.SubscriptionFromContext(ctx)
+		go func() {
+			for {
+				select {
+				case <-sub.Done():
+					return
+				case val := <-updates:
+					_ = sub.Notify(val)
+				}
+			}
+		}()
+		return sub, nil
+	}
+
+	//返回 channel 时, 方法需要在 ctx 取消后停止写入
+	func (o *Order) Updates(ctx context.Context) (<-chan *Order, error)
+	*/
+	Subscription struct {
+		ID string
+
+		conn   *rpcConn
+		cancel context.CancelFunc
+		ready  chan struct{}
+		done   chan struct{}
+		once   sync.Once
+	}
+	//subscriptionResult ...通知的参数
+	subscriptionResult struct {
+		Subscription string      `json:"subscription"`
+		Result       interface{} `json:"result"`
+	}
+	subscriptionKey struct{}
+)
+
+// SubscriptionFromContext ...在订阅方法中获取当前的订阅, 非订阅方法返回nil
+func SubscriptionFromContext(ctx context.Context) *Subscription {
+	sub, _ := ctx.Value(subscriptionKey{}).(*Subscription)
+	return sub
+}
+
+// Done ...订阅结束时关闭
+func (s *Subscription) Done() <-chan struct{} { return s.done }
+
+// Notify ...推送通知, 订阅的响应写出之前会阻塞等待
+func (s *Subscription) Notify(data interface{}) (err error) {
+	select {
+	case <-s.ready:
+	case <-s.done:
+		return ErrSubscriptionClosed
+	}
+	select {
+	case <-s.done:
+		return ErrSubscriptionClosed
+	default:
+	}
+	params, err := json.Marshal(&subscriptionResult{Subscription: s.ID, Result: data})
+	if err != nil {
+		return
+	}
+	return s.conn.write(&RPCMessage{Version: vsn, Method: subscriptionMethod, Params: params})
+}
+
+// Unsubscribe ...结束订阅, 可以重复调用
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.cancel()
+		s.conn.removeSubscription(s.ID)
+	})
+}
+
+// activate ...
+func (s *Subscription) activate() { close(s.ready) }
+
+// forward ...将 channel 中的值作为通知推送, channel 关闭时结束订阅
+func (s *Subscription) forward(ch reflect.Value) {
+	defer s.Unsubscribe()
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.done)},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	for {
+		chosen, val, ok := reflect.Select(cases)
+		if chosen == 0 || !ok {
+			return
+		}
+		if err := s.Notify(val.Interface()); err != nil {
+			return
+		}
+	}
+}
+
+// newSubscription ...返回的 ctx 不受请求超时的影响, 在订阅结束时取消
+func (c *rpcConn) newSubscription(ctx context.Context) (*Subscription, context.Context) {
+	sub := &Subscription{
+		ID:    g2util.ShortUUID(),
+		conn:  c,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	ctx, sub.cancel = context.WithCancel(context.WithValue(context.WithoutCancel(ctx), subscriptionKey{}, sub))
+
+	c.smu.Lock()
+	closed := c.closed
+	if !closed {
+		c.subs[sub.ID] = sub
+	}
+	c.smu.Unlock()
+	if closed {
+		sub.Unsubscribe()
+	}
+	return sub, ctx
+}
+
+// subscribe ...执行订阅方法, 返回订阅id
+func (s *server) subscribe(base, ctx context.Context, cbk callback, args []reflect.Value) (res interface{}, err error) {
+	f := frameFromContext(ctx)
+	if f == nil {
+		return nil, NewError(ErrInvalidRequest, "subscription requires websocket or ServeConn")
+	}
+	sub, ctx := f.conn.newSubscription(ctx)
+	defer func() {
+		if err != nil {
+			sub.Unsubscribe()
+		}
+	}()
+
+	if res, err = cbk.call(cbk.context(base, ctx), args); err != nil {
+		return
+	}
+	switch val := reflect.ValueOf(res); {
+	case val.Kind() == reflect.Chan && !val.IsNil():
+		go sub.forward(val)
+	case res == sub:
+	default:
+		return nil, NewError(ErrInternal, "subscription method must return a channel or the subscription from context")
+	}
+	//超时等原因导致响应已经写出
+	if !f.add(sub) {
+		return nil, ErrSubscriptionClosed
+	}
+	return sub.ID, nil
+}
+
+// isSubscriptionType ...
+func isSubscriptionType(t reflect.Type) bool {
+	return t == subscriptionType || (t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0)
+}
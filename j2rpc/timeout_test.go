@@ -0,0 +1,84 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type (
+	//testBase ...与 *gin.Context 相同, 带有 Copy 与 Request 的请求 context
+	testBase struct {
+		context.Context
+		Request *http.Request
+		copied  bool
+	}
+
+	baseAPI struct{}
+)
+
+func (b *testBase) Copy() *testBase {
+	cp := *b
+	cp.copied = true
+	return &cp
+}
+
+// Check ...返回是否为副本, 副本的 Request 是否带有截止时间
+func (*baseAPI) Check(c *testBase) []bool {
+	_, ok := c.Request.Context().Deadline()
+	return []bool{c.copied, ok}
+}
+
+func TestTimeout(t *testing.T) {
+	opt := &Option{SnakeNamespace: true, Timeout: time.Second}
+	opt.AddTimeout(50*time.Millisecond, []string{"test.sleep"})
+	s := newTestServer(t, opt)
+	start := time.Now()
+	resp := decodeResponse(t, postRPC(s, `{"id":1,"method":"test.sleep","params":[2000]}`))
+	if resp.errorCode() != ErrTimeout {
+		t.Errorf("sleep: %+v, want timeout", resp.Error)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("timed out after %s", d)
+	}
+	resp = decodeResponse(t, postRPC(s, `{"id":1,"method":"test.sleep","params":[1]}`))
+	if string(resp.Result) != `"done"` {
+		t.Errorf("sleep: result=%s error=%+v", resp.Result, resp.Error)
+	}
+	//参数错误在请求的 goroutine 中返回, 不受超时影响
+	resp = decodeResponse(t, postRPC(s, `{"id":1,"method":"test.sleep","params":["x"]}`))
+	if resp.errorCode() != ErrBadParams {
+		t.Errorf("bad params: %+v", resp.Error)
+	}
+}
+
+// TestTimeoutHeaders ...超时返回后方法仍在后台执行, 响应头只在请求的 goroutine 中写入, 使用 -race 检查
+func TestTimeoutHeaders(t *testing.T) {
+	s := newTestServer(t, &Option{SnakeNamespace: true, Timeout: time.Nanosecond})
+	for i := 0; i < 20; i++ {
+		w := postRPC(s, `{"id":1,"method":"test.old"}`, "Accept-Encoding", "gzip")
+		if got := w.Header().Get("Deprecation"); got != "true" {
+			t.Fatalf("Deprecation header = %q", got)
+		}
+	}
+}
+
+func TestTimeoutBaseContext(t *testing.T) {
+	for _, timeout := range []time.Duration{0, time.Second} {
+		s := newTestServer(t, &Option{SnakeNamespace: true, Timeout: timeout}, new(baseAPI))
+		r := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(`{"id":1,"method":"test.check"}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.Handler(&testBase{Context: r.Context(), Request: r}, w, r)
+		want := `[false,false]`
+		if timeout > 0 {
+			want = `[true,true]`
+		}
+		if resp := decodeResponse(t, w); string(resp.Result) != want {
+			t.Errorf("timeout %s: result=%s error=%+v, want %s", timeout, resp.Result, resp.Error, want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package j2rpc
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tsHeader ...生成代码的公共部分: 错误码, 错误类型, 传输层
+const tsHeader = `// Code generated by j2rpc. DO NOT EDIT.
+/* eslint-disable */
+
+export class J2rpcError extends Error {
+  constructor(public code: ErrorCode | number, message: string, public data?: unknown) {
+    super(message);
+  }
+}
+
+export interface Transport {
+  call<T>(method: string, params: unknown[]): Promise<T>;
+}
+
+export class HttpTransport implements Transport {
+  private id = 0;
+
+  constructor(private url: string, public headers: Record<string, string> = {}) {}
+
+  async call<T>(method: string, params: unknown[]): Promise<T> {
+    const resp = await fetch(this.url, {
+      method: "POST",
+      headers: { ...this.headers, "Content-Type": "application/json" },
+      body: JSON.stringify({ jsonrpc: "2.0", id: ++this.id, method, params }),
+    });
+    if (resp.status === 401 || resp.status === 403) {
+      throw new J2rpcError(resp.status, resp.statusText);
+    }
+    const msg = await resp.json();
+    if (msg.error) {
+      throw new J2rpcError(msg.error.code, msg.error.message, msg.error.data);
+    }
+    return msg.result as T;
+  }
+}
+`
+
+// tsBuilder ...
+type tsBuilder struct {
+	names      map[reflect.Type]string
+	interfaces map[string]string
+}
+
+// TypeScript ...生成 TypeScript 客户端代码, 每个命名空间一个类, 每个方法一个异步函数
+func (s *server) TypeScript(w io.Writer) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b := &tsBuilder{names: make(map[reflect.Type]string), interfaces: make(map[string]string)}
+	var buf strings.Builder
+	buf.WriteString(tsHeader)
+	buf.WriteString("\nexport enum ErrorCode {\n")
+	codes := make([]int, 0, len(errorCodeNames))
+	for code := range errorCodeNames {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&buf, "  %s = %d,\n", errorCodeNames[ErrorCode(code)], code)
+	}
+	buf.WriteString("}\n")
+
+	var (
+		classes  strings.Builder
+		services = make([]service, 0)
+		now      = time.Now()
+	)
+	for _, srv := range s.sortedServices() {
+		if _, ok := srv.receiver.Interface().(*rpcService); ok {
+			continue
+		}
+		services = append(services, srv)
+		fmt.Fprintf(&classes, "\nexport class %s {\n", tsServiceName(srv.name))
+		classes.WriteString("  constructor(private transport: Transport) {}\n")
+		for _, name := range srv.sortedMethods() {
+			cbk := srv.callbacks[name]
+			d := s.deprecation(srv.name, cbk)
+			if d != nil && d.removed(now) {
+				continue
+			}
+			classes.WriteString("\n")
+			classes.WriteString(b.method(srv.name+splitMethodSeparator+name, name, cbk, d))
+		}
+		classes.WriteString("}\n")
+	}
+
+	names := make([]string, 0, len(b.interfaces))
+	for name := range b.interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString("\n")
+		buf.WriteString(b.interfaces[name])
+	}
+	buf.WriteString(classes.String())
+
+	buf.WriteString("\nexport class J2rpcClient {\n")
+	for _, srv := range services {
+		fmt.Fprintf(&buf, "  readonly %s: %s;\n", tsMemberName(srv.name), tsServiceName(srv.name))
+	}
+	buf.WriteString("\n  constructor(public transport: Transport) {\n")
+	for _, srv := range services {
+		fmt.Fprintf(&buf, "    this.%s = new %s(transport);\n", tsMemberName(srv.name), tsServiceName(srv.name))
+	}
+	buf.WriteString("  }\n}\n")
+
+	_, err = io.WriteString(w, buf.String())
+	return
+}
+
+// method ...
+func (b *tsBuilder) method(fullName, name string, cbk callback, d *Deprecation) string {
+	params := make([]string, len(cbk.argTypes))
+	args := make([]string, len(cbk.argTypes))
+	//只有末尾的指针参数可以省略
+	optional := true
+	for i := len(cbk.argTypes) - 1; i >= 0; i-- {
+		argType := cbk.argTypes[i]
+		argName := fmt.Sprintf("arg%d", i)
+		if len(cbk.argNames) > 0 {
+			argName = tsMemberName(cbk.argNames[i])
+		}
+		optional = optional && argType.Kind() == reflect.Ptr
+		mark := ""
+		if optional {
+			mark = "?"
+		}
+		params[i] = fmt.Sprintf("%s%s: %s", argName, mark, b.typeOf(argType))
+		args[i] = argName
+	}
+	result := "void"
+	if rt := cbk.resultType(); rt != nil {
+		result = b.typeOf(rt)
+	}
+	comment := ""
+	if d != nil {
+		comment += strings.TrimRight("  /** @deprecated "+d.Message, " ") + " */\n"
+	}
+	if cbk.access.requireAuth() {
+		comment += fmt.Sprintf("  // access: %s\n", cbk.access)
+	}
+	if cbk.isSub {
+		comment += "  // subscription, requires a websocket transport\n"
+	}
+	return fmt.Sprintf("%s  %s(%s): Promise<%s> {\n    return this.transport.call<%s>(%q, [%s]);\n  }\n",
+		comment, tsMemberName(name), strings.Join(params, ", "), result, result, fullName, strings.Join(args, ", "))
+}
+
+// typeOf ...
+func (b *tsBuilder) typeOf(t reflect.Type) string {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+	ts := b.baseType(t)
+	if nullable {
+		return ts + " | null"
+	}
+	return ts
+}
+
+// baseType ...
+func (b *tsBuilder) baseType(t reflect.Type) string {
+	switch {
+	case t == rawMessageType:
+		return "unknown"
+	case t == timeType || (t.Kind() == reflect.Struct && t.ConvertibleTo(timeType)):
+		return "string"
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return "unknown"
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		elem := b.typeOf(t.Elem())
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return fmt.Sprintf("Record<string, %s>", b.typeOf(t.Elem()))
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return b.structBody(t, "")
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = uniqueTypeName(t, func(name string) bool { _, ok := b.interfaces[tsClassName(name)]; return ok })
+			name = tsClassName(name)
+			b.names[t] = name
+			//先占位, 防止递归类型无限循环
+			b.interfaces[name] = ""
+			b.interfaces[name] = fmt.Sprintf("export interface %s %s\n", name, b.structBody(t, ""))
+		}
+		return name
+	default:
+		return "unknown"
+	}
+}
+
+// structBody ...
+func (b *tsBuilder) structBody(t reflect.Type, indent string) string {
+	var buf strings.Builder
+	buf.WriteString("{\n")
+	b.writeFields(&buf, t, indent+"  ")
+	buf.WriteString(indent + "}")
+	return buf.String()
+}
+
+// writeFields ...
+func (b *tsBuilder) writeFields(buf *strings.Builder, t reflect.Type, indent string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, omitempty, skip := jsonFieldName(f)
+		if skip {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && len(name) == 0 {
+			b.writeFields(buf, ft, indent)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		mark := ""
+		if omitempty {
+			mark = "?"
+		}
+		fmt.Fprintf(buf, "%s%s%s: %s;\n", indent, tsPropertyName(name), mark, b.typeOf(f.Type))
+	}
+}
+
+// tsClassName ...user_info => UserInfo
+func tsClassName(name string) string {
+	return CamelString(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// tsServiceName ...user_info => UserInfoService
+func tsServiceName(name string) string { return tsClassName(name) + "Service" }
+
+// tsMemberName ...user_info => userInfo
+func tsMemberName(name string) string {
+	name = tsClassName(name)
+	if len(name) == 0 {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// tsPropertyName ...
+func tsPropertyName(name string) string {
+	if schemaNameReplacer.MatchString(name) {
+		return fmt.Sprintf("%q", name)
+	}
+	return name
+}
@@ -0,0 +1,67 @@
+package j2rpc
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+type tsAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type tsUser struct {
+	ID       int64             `json:"id"`
+	Name     string            `json:"name"`
+	Email    *string           `json:"email,omitempty"`
+	Address  tsAddress         `json:"address"`
+	Previous []*tsAddress      `json:"previous,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Created  time.Time         `json:"created"`
+	password string
+}
+
+type tsFilter struct {
+	Name  string `json:"name,omitempty"`
+	Limit int    `json:"limit"`
+}
+
+type tsAPI struct{}
+
+func (*tsAPI) GetUser(id int64) (*tsUser, error) { return nil, nil }
+
+func (*tsAPI) FindUsers(filter *tsFilter) ([]tsUser, error) { return nil, nil }
+
+func (*tsAPI) UpdateUser(id int64, user tsUser, notify *bool) error { return nil }
+
+func (*tsAPI) J2rpcParamNames() map[string][]string {
+	return map[string][]string{"GetUser": {"user_id"}, "UpdateUser": {"user_id", "user", "notify"}}
+}
+
+// TestTypeScriptGolden ...go test -run TestTypeScriptGolden -update 更新 testdata/typescript.golden
+func TestTypeScriptGolden(t *testing.T) {
+	s := newTestServer(t, nil, new(tsAPI))
+	buf := new(bytes.Buffer)
+	if err := s.TypeScript(buf); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "typescript.golden")
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("TypeScript output differs from %s:\n%s", golden, got)
+	}
+}
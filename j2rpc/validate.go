@@ -0,0 +1,126 @@
+package j2rpc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/atcharles/gof/v2/g2util"
+)
+
+// ItfNoValidate ...声明不需要自动校验参数的方法名称
+type ItfNoValidate interface{ J2rpcNoValidate() []string }
+
+// validateArguments ...使用 g2util.Valid 校验带有 validate 标签的结构体参数, Data 为字段错误列表
+func (c *callback) validateArguments(args []reflect.Value) error {
+	var list []g2util.FieldError
+	for _, i := range c.validArgs {
+		arg := args[i]
+		if arg.Kind() == reflect.Ptr && arg.IsNil() {
+			continue
+		}
+		err := g2util.Valid.Valid().Struct(arg.Interface())
+		if err == nil {
+			continue
+		}
+		fields, ok := g2util.Valid.TranslateFields(err)
+		if !ok {
+			return NewError(ErrBadParams, err.Error())
+		}
+		prefix := ""
+		if len(c.argTypes) > 1 {
+			prefix = fmt.Sprintf("arg%d", i)
+			if len(c.argNames) > 0 {
+				prefix = c.argNames[i]
+			}
+		}
+		for _, f := range fields {
+			f.Field = jsonFieldPath(c.argTypes[i], f.Field)
+			if len(prefix) > 0 {
+				f.Field = prefix + "." + f.Field
+			}
+			list = append(list, f)
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return fieldsError(list)
+}
+
+// validateArgs ...需要校验的参数下标
+func validateArgs(types []reflect.Type) (list []int) {
+	for i, t := range types {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct && hasValidateTag(t, make(map[reflect.Type]bool)) {
+			list = append(list, i)
+		}
+	}
+	return
+}
+
+// hasValidateTag ...结构体或嵌套的结构体中是否有 validate 标签
+func hasValidateTag(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag := f.Tag.Get("validate"); len(tag) > 0 && tag != "-" {
+			return true
+		}
+		if hasValidateTag(f.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFieldPath ...将结构体字段路径转换为 json 字段路径, 如 Items[0].UserName => items[0].user_name
+func jsonFieldPath(t reflect.Type, path string) string {
+	segments := strings.Split(path, ".")
+	out := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		name, index := seg, ""
+		if i := strings.IndexByte(seg, '['); i >= 0 {
+			name, index = seg[:i], seg[i:]
+		}
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			out = append(out, seg)
+			continue
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			out = append(out, seg)
+			continue
+		}
+		t = f.Type
+		for i := 0; i < strings.Count(index, "["); i++ {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+		jsonName, _, _ := jsonFieldName(f)
+		//匿名嵌入的结构体, 字段在 json 中提升到上一层
+		if f.Anonymous && len(jsonName) == 0 {
+			continue
+		}
+		if len(jsonName) == 0 {
+			jsonName = f.Name
+		}
+		out = append(out, jsonName+index)
+	}
+	return strings.Join(out, ".")
+}
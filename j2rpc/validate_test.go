@@ -0,0 +1,86 @@
+package j2rpc
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/atcharles/gof/v2/g2util"
+	"github.com/atcharles/gof/v2/json"
+)
+
+type (
+	validAPI struct{}
+
+	validAddress struct {
+		City string `json:"city" validate:"required"`
+	}
+
+	validUser struct {
+		Name    string        `json:"name" validate:"required,username"`
+		Mobile  string        `json:"mobile" validate:"omitempty,mobile"`
+		Address *validAddress `json:"address"`
+	}
+)
+
+func (*validAPI) Create(u validUser) string { return u.Name }
+
+func (*validAPI) Raw(u validUser) string { return u.Name }
+
+func (*validAPI) J2rpcNoValidate() []string { return []string{"Raw"} }
+
+func TestValidateArguments(t *testing.T) {
+	s := newTestServer(t, nil, new(validAPI))
+	tests := []struct {
+		body   string
+		fields []string
+	}{
+		{`{"id":1,"method":"test.create","params":[{"name":"user1"}]}`, nil},
+		{`{"id":1,"method":"test.create","params":[{"name":"u"}]}`, []string{"name"}},
+		{`{"id":1,"method":"test.create","params":[{"name":"user1","mobile":"123"}]}`, []string{"mobile"}},
+		{`{"id":1,"method":"test.create","params":[{"name":"user1","address":{}}]}`, []string{"address.city"}},
+		{`{"id":1,"method":"test.create","params":[{"name":"u","mobile":"1"}]}`, []string{"name", "mobile"}},
+		{`{"id":1,"method":"test.raw","params":[{"name":"u"}]}`, nil},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Error *struct {
+				Code ErrorCode           `json:"code"`
+				Data []g2util.FieldError `json:"data"`
+			} `json:"error"`
+		}
+		w := postRPC(s, tt.body)
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(tt.fields) == 0 {
+			if resp.Error != nil {
+				t.Errorf("%s: %s", tt.body, w.Body)
+			}
+			continue
+		}
+		if resp.Error == nil || resp.Error.Code != ErrBadParams || len(resp.Error.Data) != len(tt.fields) {
+			t.Errorf("%s: %s, want fields %v", tt.body, w.Body, tt.fields)
+			continue
+		}
+		for i, f := range resp.Error.Data {
+			if f.Field != tt.fields[i] || len(f.Message) == 0 {
+				t.Errorf("%s: field %d = %+v, want %s", tt.body, i, f, tt.fields[i])
+			}
+		}
+	}
+}
+
+// TestValidateSchema ...OpenRPC 文档与校验规则使用相同的正则
+func TestValidateSchema(t *testing.T) {
+	sc := newSchemaBuilder().structSchema(reflect.TypeOf(validUser{}))
+	if got := sc.Properties["name"].Pattern; got != g2util.PatternUsername {
+		t.Errorf("name pattern = %q", got)
+	}
+	if got := sc.Properties["mobile"].Pattern; got != g2util.PatternMobile {
+		t.Errorf("mobile pattern = %q", got)
+	}
+	if !slices.Equal(sc.Required, []string{"name"}) {
+		t.Errorf("required = %v", sc.Required)
+	}
+}
@@ -0,0 +1,153 @@
+package j2rpc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultVersionHeader ...
+const defaultVersionHeader = "X-Api-Version"
+
+type (
+	//ItfVersion ...声明命名空间的版本, 如 v2, 注册为 v2.user; 用于 RegisterForApp
+	/**
+	同一个命名空间可以注册多个版本, 旧的客户端继续调用 user.get, 新的客户端调用 v2.user.get,
+	或者在请求头 X-Api-Version(Option.VersionHeader) 中指定版本:
+
+	s.Register(new(User), "user")
+	s.Register(new(UserV2), "user", "v2")
+	*/
+	ItfVersion interface{ J2rpcVersion() string }
+
+	//ItfDeprecated ...声明废弃的方法, key 为方法名, "*" 为整个命名空间
+	/**
+	func (u *User) J2rpcDeprecated() map[string]j2rpc.Deprecation {
+		return map[string]j2rpc.Deprecation{
+			"Get": {Message: "use v2.user.get", Sunset: time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local)},
+		}
+	}
+	*/
+	ItfDeprecated interface{ J2rpcDeprecated() map[string]Deprecation }
+
+	//Deprecation ...废弃的方法被调用时, 响应头中输出 Deprecation 与 Sunset, 并记录日志
+	Deprecation struct {
+		//开始废弃的时间, 为零值时立即废弃
+		Since time.Time
+		//计划移除的时间, 之后调用返回 ErrNoMethod; 为零值时不移除
+		Sunset time.Time
+		//替代的方法等说明, 记录在日志中
+		Message string
+	}
+)
+
+// DeprecateVersion ...废弃一个版本的所有命名空间, 如 v1; 版本移除之前, 方法或命名空间在 ItfDeprecated 中的声明优先
+func (o *Option) DeprecateVersion(version string, d Deprecation) {
+	if o.DeprecatedVersions == nil {
+		o.DeprecatedVersions = make(map[string]Deprecation)
+	}
+	o.DeprecatedVersions[version] = d
+}
+
+// versionHeader ...
+func (o *Option) versionHeader() string {
+	if len(o.VersionHeader) > 0 {
+		return o.VersionHeader
+	}
+	return defaultVersionHeader
+}
+
+// removed ...
+func (d *Deprecation) removed(now time.Time) bool { return !d.Sunset.IsZero() && !now.Before(d.Sunset) }
+
+// active ...
+func (d *Deprecation) active(now time.Time) bool { return d.Since.IsZero() || !now.Before(d.Since) }
+
+// headerValue ...https://www.rfc-editor.org/rfc/rfc9745
+func (d *Deprecation) headerValue() string {
+	if d.Since.IsZero() {
+		return "true"
+	}
+	return "@" + strconv.FormatInt(d.Since.Unix(), 10)
+}
+
+// versionedName ...版本与命名空间组成的服务名称, 如 v2.user
+func versionedName(version, namespace string) string {
+	if len(version) == 0 {
+		return namespace
+	}
+	return version + splitMethodSeparator + namespace
+}
+
+// namespaceVersion ...v2.user => v2
+func namespaceVersion(namespace string) string {
+	version, _, ok := strings.Cut(namespace, splitMethodSeparator)
+	if !ok {
+		return ""
+	}
+	return version
+}
+
+// resolveVersion ...请求头中指定了版本, 且方法没有指定版本时, 优先使用该版本的命名空间
+func (s *server) resolveVersion(r *http.Request, namespace string) string {
+	if r == nil || len(namespaceVersion(namespace)) > 0 {
+		return namespace
+	}
+	version := s.formatName(strings.TrimSpace(r.Header.Get(s.opt.versionHeader())))
+	if len(version) == 0 {
+		return namespace
+	}
+	if _, ok := s.services[versionedName(version, namespace)]; ok {
+		return versionedName(version, namespace)
+	}
+	return namespace
+}
+
+// deprecation ...方法的废弃声明, 没有废弃时为nil; 版本已经移除时, 忽略方法的声明
+func (s *server) deprecation(namespace string, cbk callback) *Deprecation {
+	vd, ok := s.opt.DeprecatedVersions[namespaceVersion(namespace)]
+	if ok && vd.removed(time.Now()) {
+		return &vd
+	}
+	if cbk.deprecation != nil {
+		return cbk.deprecation
+	}
+	if ok {
+		return &vd
+	}
+	return nil
+}
+
+// checkDeprecation ...已经移除的方法返回 ErrNoMethod; 废弃的方法输出响应头并记录日志
+func (s *server) checkDeprecation(w http.ResponseWriter, namespace, method string, cbk callback) error {
+	d := s.deprecation(namespace, cbk)
+	if d == nil {
+		return nil
+	}
+	now := time.Now()
+	if d.removed(now) {
+		return NewError(ErrNoMethod, fmt.Sprintf("method %s was removed at %s", method, d.Sunset.Format(time.RFC3339)))
+	}
+	if !d.active(now) {
+		return nil
+	}
+	h := w.Header()
+	h.Set("Deprecation", d.headerValue())
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	msg := fmt.Sprintf("[Deprecated] %s is deprecated", method)
+	if !d.Sunset.IsZero() {
+		msg += ", will be removed at " + d.Sunset.Format(time.RFC3339)
+	}
+	if len(d.Message) > 0 {
+		msg += ", " + d.Message
+	}
+	if requestID := h.Get("request-id"); len(requestID) > 0 {
+		msg = fmt.Sprintf("[Request-ID:%s] %s", requestID, msg)
+	}
+	s.logger.Warnf("%s", msg)
+	return nil
+}
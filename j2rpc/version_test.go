@@ -0,0 +1,139 @@
+package j2rpc
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testSince  = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	testSunset = time.Now().Add(time.Hour * 24 * 30).Truncate(time.Second)
+)
+
+type (
+	userV1 struct{}
+	userV2 struct{}
+)
+
+func (*userV1) Get() string { return "v1" }
+
+func (*userV1) Gone() string { return "gone" }
+
+func (*userV1) Later() string { return "later" }
+
+func (*userV1) J2rpcDeprecated() map[string]Deprecation {
+	return map[string]Deprecation{
+		"Get":   {Since: testSince, Sunset: testSunset, Message: "use v2.user.get"},
+		"Gone":  {Sunset: time.Now().Add(-time.Hour)},
+		"Later": {Since: time.Now().Add(time.Hour)},
+	}
+}
+
+func (*userV2) Get() string { return "v2" }
+
+// newVersionServer ...user 与 v2.user 两个版本
+func newVersionServer(t *testing.T, opt *Option) (RPCServer, *bytes.Buffer) {
+	t.Helper()
+	opt.SnakeNamespace, opt.DisableMetrics = true, true
+	s := New(opt)
+	logs := new(bytes.Buffer)
+	s.Logger().SetOutput(logs)
+	s.Register(new(userV1), "user")
+	s.Register(new(userV2), "user", "v2")
+	return s, logs
+}
+
+func TestVersionRouting(t *testing.T) {
+	s, _ := newVersionServer(t, new(Option))
+	tests := []struct {
+		method string
+		header []string
+		want   string
+	}{
+		{"user.get", nil, `"v1"`},
+		{"v2.user.get", nil, `"v2"`},
+		{"user.get", []string{"X-Api-Version", "v2"}, `"v2"`},
+		{"user.get", []string{"X-Api-Version", " V2 "}, `"v2"`},
+		//没有注册的版本使用默认的命名空间
+		{"user.get", []string{"X-Api-Version", "v3"}, `"v1"`},
+		//方法中指定的版本优先
+		{"v2.user.get", []string{"X-Api-Version", "v1"}, `"v2"`},
+	}
+	for _, tt := range tests {
+		w := postRPC(s, `{"id":1,"method":"`+tt.method+`"}`, tt.header...)
+		if resp := decodeResponse(t, w); string(resp.Result) != tt.want {
+			t.Errorf("%s %v: result %s error %v, want %s", tt.method, tt.header, resp.Result, resp.Error, tt.want)
+		}
+	}
+
+	s, _ = newVersionServer(t, &Option{VersionHeader: "Accept-Version"})
+	resp := decodeResponse(t, postRPC(s, `{"id":1,"method":"user.get"}`, "Accept-Version", "v2"))
+	if string(resp.Result) != `"v2"` {
+		t.Errorf("VersionHeader: result %s", resp.Result)
+	}
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	s, logs := newVersionServer(t, new(Option))
+	w := postRPC(s, `{"id":1,"method":"user.get"}`, "X-Api-Version", "v1")
+	if got, want := w.Header().Get("Deprecation"), "@"+strconv.FormatInt(testSince.Unix(), 10); got != want {
+		t.Errorf("Deprecation = %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Sunset"), testSunset.UTC().Format(http.TimeFormat); got != want {
+		t.Errorf("Sunset = %q, want %q", got, want)
+	}
+	if resp := decodeResponse(t, w); string(resp.Result) != `"v1"` {
+		t.Errorf("deprecated method: result %s error %v", resp.Result, resp.Error)
+	}
+	if !strings.Contains(logs.String(), "user.get is deprecated") || !strings.Contains(logs.String(), "use v2.user.get") {
+		t.Errorf("deprecation not logged:\n%s", logs.String())
+	}
+
+	//废弃时间之前不输出响应头
+	w = postRPC(s, `{"id":1,"method":"user.later"}`)
+	if got := w.Header().Get("Deprecation"); len(got) > 0 || string(decodeResponse(t, w).Result) != `"later"` {
+		t.Errorf("before Since: Deprecation = %q, body %s", got, w.Body.String())
+	}
+	w = postRPC(s, `{"id":1,"method":"v2.user.get"}`)
+	if got := w.Header().Get("Deprecation"); len(got) > 0 {
+		t.Errorf("v2: Deprecation = %q", got)
+	}
+}
+
+func TestSunsetRemoved(t *testing.T) {
+	s, _ := newVersionServer(t, new(Option))
+	resp := decodeResponse(t, postRPC(s, `{"id":1,"method":"user.gone"}`))
+	if resp.errorCode() != ErrNoMethod || !strings.Contains(resp.Error.Message, "was removed") {
+		t.Errorf("after Sunset: %+v", resp.Error)
+	}
+
+	opt := new(Option)
+	opt.DeprecateVersion("v2", Deprecation{Sunset: time.Now().Add(-time.Minute)})
+	s, _ = newVersionServer(t, opt)
+	for _, header := range [][]string{nil, {"X-Api-Version", "v2"}} {
+		method := "v2.user.get"
+		if len(header) > 0 {
+			method = "user.get"
+		}
+		resp = decodeResponse(t, postRPC(s, `{"id":1,"method":"`+method+`"}`, header...))
+		if resp.errorCode() != ErrNoMethod {
+			t.Errorf("%s %v: removed version returned %s %v", method, header, resp.Result, resp.Error)
+		}
+	}
+	//移除的版本不影响没有版本的命名空间
+	if resp = decodeResponse(t, postRPC(s, `{"id":1,"method":"user.later"}`)); string(resp.Result) != `"later"` {
+		t.Errorf("user.later: %s %v", resp.Result, resp.Error)
+	}
+
+	opt = new(Option)
+	opt.DeprecateVersion("v2", Deprecation{Sunset: testSunset})
+	s, _ = newVersionServer(t, opt)
+	w := postRPC(s, `{"id":1,"method":"v2.user.get"}`)
+	if w.Header().Get("Deprecation") != "true" || len(w.Header().Get("Sunset")) == 0 {
+		t.Errorf("deprecated version: headers %v", w.Header())
+	}
+}
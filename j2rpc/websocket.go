@@ -0,0 +1,81 @@
+package j2rpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait    = time.Second * 10
+	wsPongWait     = time.Second * 60
+	wsPingInterval = wsPongWait / 2
+)
+
+// wsTransport ...websocket 连接, 一个消息帧为一个单个或批量请求
+type wsTransport struct {
+	conn *websocket.Conn
+	done chan struct{}
+	once sync.Once
+}
+
+// ServeWebsocket ...升级为 websocket 连接, 每个消息为一个单个或批量请求, 响应通过 id 与请求对应
+func (s *server) ServeWebsocket(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.run) == 0 {
+		return
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: s.opt.WebsocketCheckOrigin}
+	if upgrader.CheckOrigin == nil {
+		upgrader.CheckOrigin = func(*http.Request) bool { return true }
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Errorf("[Websocket] upgrade: %s", err.Error())
+		return
+	}
+	conn.SetReadLimit(s.opt.maxRequestContentLength())
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error { return conn.SetReadDeadline(time.Now().Add(wsPongWait)) })
+	tr := &wsTransport{conn: conn, done: make(chan struct{})}
+	go tr.ping()
+	s.newConn(ctx, r, tr).serve()
+}
+
+// ping ...定时发送 ping, 客户端的 pong 延长读取期限
+func (t *wsTransport) ping() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			if err := t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (t *wsTransport) readMessage() ([]byte, error) {
+	_, body, err := t.conn.ReadMessage()
+	if err != nil && !websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		//只记录意外的关闭
+		return nil, errConnClosed
+	}
+	return body, err
+}
+
+func (t *wsTransport) writeMessage(msg []byte) error {
+	_ = t.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return t.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+func (t *wsTransport) close() error {
+	t.once.Do(func() { close(t.done) })
+	return t.conn.Close()
+}
@@ -0,0 +1,153 @@
+package j2rpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/atcharles/gof/v2/json"
+)
+
+// wsAPI ...订阅结束(ctx 取消)时将订阅id写入 cancelled
+type wsAPI struct{ cancelled chan string }
+
+// Watch ...推送 1..n, 之后等待订阅结束
+func (a *wsAPI) Watch(ctx context.Context, n int) (*Subscription, error) {
+	sub := SubscriptionFromContext(ctx)
+	go func() {
+		for i := 1; i <= n; i++ {
+			if sub.Notify(i) != nil {
+				break
+			}
+		}
+		<-ctx.Done()
+		a.cancelled <- sub.ID
+	}()
+	return sub, nil
+}
+
+// wsMessage ...响应或通知
+type wsMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// dialWebsocket ...
+func dialWebsocket(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// wsCall ...发送请求并读取一个消息
+func wsCall(t *testing.T, conn *websocket.Conn, body string) *wsMessage {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return wsRead(t, conn)
+}
+
+// wsRead ...
+func wsRead(t *testing.T, conn *websocket.Conn) *wsMessage {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	_, bts, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := new(wsMessage)
+	if err = json.Unmarshal(bts, msg); err != nil {
+		t.Fatalf("decode %q: %v", bts, err)
+	}
+	return msg
+}
+
+// waitCancelled ...
+func waitCancelled(t *testing.T, api *wsAPI, id string) {
+	t.Helper()
+	select {
+	case got := <-api.cancelled:
+		if got != id {
+			t.Errorf("cancelled subscription %s, want %s", got, id)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("subscription %s not cancelled", id)
+	}
+}
+
+func TestWebsocketRoundTrip(t *testing.T) {
+	api := &wsAPI{cancelled: make(chan string, 2)}
+	s := New(&Option{SnakeNamespace: true, DisableMetrics: true})
+	s.Logger().SetOutput(new(bytes.Buffer))
+	s.Register(new(testAPI), "test")
+	s.Register(api, "ws")
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeWebsocket(r.Context(), w, r)
+	}))
+	defer hs.Close()
+
+	conn := dialWebsocket(t, hs.URL)
+	defer func() { _ = conn.Close() }()
+	if msg := wsCall(t, conn, `{"id":1,"method":"test.echo","params":["hi"]}`); string(msg.ID) != "1" ||
+		string(msg.Result) != `"hi"` {
+		t.Fatalf("call: id %s result %s error %v", msg.ID, msg.Result, msg.Error)
+	}
+
+	msg := wsCall(t, conn, `{"id":2,"method":"ws.watch","params":[2]}`)
+	var subID string
+	if err := json.Unmarshal(msg.Result, &subID); err != nil || string(msg.ID) != "2" || len(subID) == 0 {
+		t.Fatalf("subscribe: id %s result %s error %v", msg.ID, msg.Result, msg.Error)
+	}
+	for i := 1; i <= 2; i++ {
+		msg = wsRead(t, conn)
+		var params subscriptionResult
+		if err := json.Unmarshal(msg.Params, &params); err != nil || msg.Method != subscriptionMethod ||
+			len(msg.ID) > 0 {
+			t.Fatalf("notification %d: %+v", i, msg)
+		}
+		if params.Subscription != subID || params.Result != float64(i) {
+			t.Errorf("notification %d: %+v", i, params)
+		}
+	}
+
+	msg = wsCall(t, conn, `{"id":3,"method":"rpc.unsubscribe","params":["`+subID+`"]}`)
+	if string(msg.Result) != "true" {
+		t.Fatalf("unsubscribe: result %s error %v", msg.Result, msg.Error)
+	}
+	waitCancelled(t, api, subID)
+	//false 为零值, 响应中不输出 result
+	msg = wsCall(t, conn, `{"id":4,"method":"rpc.unsubscribe","params":["`+subID+`"]}`)
+	if len(msg.Result) > 0 || msg.Error != nil {
+		t.Errorf("unsubscribe twice: result %s error %v", msg.Result, msg.Error)
+	}
+
+	//连接关闭时结束订阅
+	msg = wsCall(t, conn, `{"id":5,"method":"ws.watch","params":[0]}`)
+	if err := json.Unmarshal(msg.Result, &subID); err != nil {
+		t.Fatalf("subscribe: %s %v", msg.Result, msg.Error)
+	}
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_ = conn.Close()
+	waitCancelled(t, api, subID)
+}
+
+// TestWebsocketSubscriptionHTTP ...http 请求中不能订阅
+func TestWebsocketSubscriptionHTTP(t *testing.T) {
+	s := newTestServer(t, nil, &wsAPI{cancelled: make(chan string, 1)})
+	if resp := decodeResponse(t, postRPC(s, `{"id":1,"method":"test.watch","params":[1]}`)); resp.Error == nil {
+		t.Errorf("subscription over http: %s", resp.Result)
+	}
+}